@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxDepth limits how deeply aliases and macros may call each other, so a
+// macro that calls itself fails instead of running forever.
+const maxDepth = 10
+
+var errTooDeep = errors.New("aliases and macros nested too deeply")
+
+// placeholder matches $1 to $9 and $@ in macro steps.
+var placeholder = regexp.MustCompile(`\$([1-9@])`)
+
+func commandAlias(ctx context.Context, c *config, name ...string) error {
+	switch {
+	case len(name) == 0:
+		if len(c.settings.Aliases) == 0 {
+			fmt.Fprintln(c.out, "No aliases defined")
+		}
+		for _, key := range sortedKeys(c.settings.Aliases) {
+			fmt.Fprintf(c.out, " - %s = %s\n", key, c.settings.Aliases[key])
+		}
+		return nil
+	case name[0] == "-d":
+		if len(name) != 2 {
+			return fmt.Errorf("usage: alias -d <name>")
+		}
+		if !c.commands.undefine(name[1], "alias") {
+			return fmt.Errorf("no alias '%s'", name[1])
+		}
+		delete(c.settings.Aliases, name[1])
+		fmt.Fprintf(c.out, "Removed alias %s\n", name[1])
+		return saveSettings(c)
+	case len(name) == 1:
+		target, ok := c.settings.Aliases[name[0]]
+		if !ok {
+			return fmt.Errorf("no alias '%s'", name[0])
+		}
+		fmt.Fprintf(c.out, "%s = %s\n", name[0], target)
+		return nil
+	}
+
+	if _, ok := c.commands.lookup(name[1]); !ok {
+		return fmt.Errorf("unknown command '%s'", name[1])
+	}
+	target := strings.Join(name[1:], " ")
+	if err := defineAlias(c, name[0], target); err != nil {
+		return err
+	}
+	if c.settings.Aliases == nil {
+		c.settings.Aliases = make(map[string]string)
+	}
+	c.settings.Aliases[name[0]] = target
+	fmt.Fprintf(c.out, "Alias %s = %s\n", name[0], target)
+	return saveSettings(c)
+}
+
+// defineAlias makes name run target with any arguments appended.
+func defineAlias(c *config, name, target string) error {
+	words := strings.Fields(target)
+	return c.commands.define(cliCommand{
+		name:        name,
+		description: "Alias for " + target,
+		usage:       name + " [args...]",
+		maxArgs:     anyArgs,
+		source:      "alias",
+		callback: func(ctx context.Context, c *config, args ...string) error {
+			return c.execute(ctx, slices.Concat(words, args))
+		},
+	})
+}
+
+func commandMacro(ctx context.Context, c *config, name ...string) error {
+	switch {
+	case len(name) == 0:
+		if len(c.settings.Macros) == 0 {
+			fmt.Fprintln(c.out, "No macros defined")
+		}
+		for _, key := range sortedKeys(c.settings.Macros) {
+			fmt.Fprintf(c.out, " - %s: %s\n", key, strings.Join(c.settings.Macros[key], "; "))
+		}
+		return nil
+	case name[0] == "-d":
+		if len(name) != 2 {
+			return fmt.Errorf("usage: macro -d <name>")
+		}
+		if !c.commands.undefine(name[1], "macro") {
+			return fmt.Errorf("no macro '%s'", name[1])
+		}
+		delete(c.settings.Macros, name[1])
+		fmt.Fprintf(c.out, "Removed macro %s\n", name[1])
+		return saveSettings(c)
+	case len(name) == 1:
+		steps, ok := c.settings.Macros[name[0]]
+		if !ok {
+			return fmt.Errorf("no macro '%s'", name[0])
+		}
+		fmt.Fprintf(c.out, "%s: %s\n", name[0], strings.Join(steps, "; "))
+		return nil
+	}
+
+	steps := []string{}
+	for _, step := range strings.Split(strings.Join(name[1:], " "), ";") {
+		step = strings.TrimSpace(step)
+		if step == "" {
+			continue
+		}
+		command := strings.Fields(step)[0]
+		if _, ok := c.commands.lookup(command); !ok && command != name[0] {
+			return fmt.Errorf("unknown command '%s'", command)
+		}
+		steps = append(steps, step)
+	}
+	if len(steps) == 0 {
+		return fmt.Errorf("usage: macro <name> <command> [; <command>...]")
+	}
+	if err := defineMacro(c, name[0], steps); err != nil {
+		return err
+	}
+	if c.settings.Macros == nil {
+		c.settings.Macros = make(map[string][]string)
+	}
+	c.settings.Macros[name[0]] = steps
+	fmt.Fprintf(c.out, "Macro %s: %s\n", name[0], strings.Join(steps, "; "))
+	return saveSettings(c)
+}
+
+// defineMacro makes name run steps in order, with $1 to $9 replaced by its
+// arguments and $@ by all of them. It stops at the first failing step.
+func defineMacro(c *config, name string, steps []string) error {
+	needed, rest := 0, false
+	for _, step := range steps {
+		for _, match := range placeholder.FindAllStringSubmatch(step, -1) {
+			if match[1] == "@" {
+				rest = true
+				continue
+			}
+			n, _ := strconv.Atoi(match[1])
+			needed = max(needed, n)
+		}
+	}
+
+	usage := name
+	for i := 1; i <= needed; i++ {
+		usage += fmt.Sprintf(" <arg%d>", i)
+	}
+	maxArgs := needed
+	if rest {
+		usage += " [args...]"
+		maxArgs = anyArgs
+	}
+
+	return c.commands.define(cliCommand{
+		name:        name,
+		description: "Macro: " + strings.Join(steps, "; "),
+		usage:       usage,
+		minArgs:     needed,
+		maxArgs:     maxArgs,
+		source:      "macro",
+		callback: func(ctx context.Context, c *config, args ...string) error {
+			for _, step := range steps {
+				words := strings.Fields(expandPlaceholders(step, args))
+				err := c.execute(ctx, words)
+				if errors.Is(err, errTooDeep) {
+					return err
+				}
+				if err != nil {
+					return fmt.Errorf("%s: %w", strings.Join(words, " "), err)
+				}
+			}
+			return nil
+		},
+	})
+}
+
+func expandPlaceholders(step string, args []string) string {
+	return placeholder.ReplaceAllStringFunc(step, func(match string) string {
+		if match == "$@" {
+			return strings.Join(args, " ")
+		}
+		n, _ := strconv.Atoi(match[1:])
+		if n > len(args) {
+			return ""
+		}
+		return args[n-1]
+	})
+}
+
+// registerUserCommands defines the aliases and macros from c.settings,
+// warning about any that can't be defined, such as one that would shadow
+// a built-in command after an upgrade.
+func registerUserCommands(c *config) {
+	for _, name := range sortedKeys(c.settings.Aliases) {
+		if err := defineAlias(c, name, c.settings.Aliases[name]); err != nil {
+			fmt.Fprintf(c.out, "Warning: alias %s ignored: %v\n", name, err)
+		}
+	}
+	for _, name := range sortedKeys(c.settings.Macros) {
+		if err := defineMacro(c, name, c.settings.Macros[name]); err != nil {
+			fmt.Fprintf(c.out, "Warning: macro %s ignored: %v\n", name, err)
+		}
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
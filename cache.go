@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+func commandCache(ctx context.Context, c *config, name ...string) error {
+	switch name[0] {
+	case "stats":
+		stats := c.cache.Stats()
+		hitRate := 0.0
+		if lookups := stats.Hits + stats.Misses; lookups > 0 {
+			hitRate = float64(stats.Hits) / float64(lookups) * 100
+		}
+		fmt.Fprintf(c.out, "Entries: %d\n", stats.Entries)
+		fmt.Fprintf(c.out, "Size: %.1f KB\n", float64(stats.Bytes)/1024)
+		if stats.RawBytes != stats.Bytes {
+			fmt.Fprintf(c.out, "Uncompressed: %.1f KB (%.1fx)\n", float64(stats.RawBytes)/1024, stats.CompressionRatio())
+		}
+		fmt.Fprintf(c.out, "Hits: %d\n", stats.Hits)
+		fmt.Fprintf(c.out, "Misses: %d\n", stats.Misses)
+		fmt.Fprintf(c.out, "Hit rate: %.1f%%\n", hitRate)
+		fmt.Fprintf(c.out, "Evictions: %d\n", stats.Evictions)
+		fmt.Fprintf(c.out, "Expirations: %d\n", stats.Expirations)
+		if n, err := c.client.RevalidationErrors(); n > 0 {
+			fmt.Fprintf(c.out, "Failed background revalidations: %d (last: %v)\n", n, err)
+		}
+	case "list":
+		keys := c.cache.Keys()
+		if len(keys) == 0 {
+			fmt.Fprintln(c.out, "The cache is empty")
+		}
+		for _, key := range keys {
+			fmt.Fprintf(c.out, " - %s\n", key)
+		}
+	case "clear":
+		fmt.Fprintf(c.out, "Cleared %d entries\n", c.cache.Clear())
+	case "evict":
+		if len(name) < 2 {
+			return fmt.Errorf("please provide a URL to evict")
+		}
+		if !c.cache.Delete(name[1]) {
+			return fmt.Errorf("'%s' is not cached", name[1])
+		}
+		fmt.Fprintf(c.out, "Evicted %s\n", name[1])
+	default:
+		return fmt.Errorf("unknown cache command '%s'", name[0])
+	}
+	return nil
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// anyArgs as maxArgs lets a command take any number of arguments.
+const anyArgs = -1
+
+type cliCommand struct {
+	name        string
+	description string
+	usage       string
+	help        string
+	aliases     []string
+	minArgs     int
+	maxArgs     int
+	callback    func(context.Context, *config, ...string) error
+	// source is "alias" or "macro" for user-defined commands and empty
+	// for built-in ones.
+	source string
+}
+
+// checkArgs reports a usage error if args don't fit the command, so
+// callbacks can index the arguments they require.
+func (cmd cliCommand) checkArgs(args []string) error {
+	if len(args) < cmd.minArgs || (cmd.maxArgs != anyArgs && len(args) > cmd.maxArgs) {
+		return fmt.Errorf("usage: %s", cmd.usage)
+	}
+	return nil
+}
+
+// registry holds the REPL commands by name and alias.
+type registry struct {
+	commands map[string]cliCommand
+	aliases  map[string]string
+}
+
+func newRegistry(commands ...cliCommand) *registry {
+	r := &registry{
+		commands: make(map[string]cliCommand),
+		aliases:  make(map[string]string),
+	}
+	for _, cmd := range commands {
+		r.register(cmd)
+	}
+	return r
+}
+
+// register adds cmd. Names and aliases must be unique; a clash is a
+// programming error and panics.
+func (r *registry) register(cmd cliCommand) {
+	for _, name := range append([]string{cmd.name}, cmd.aliases...) {
+		if _, ok := r.lookup(name); ok {
+			panic(fmt.Sprintf("command %q registered twice", name))
+		}
+	}
+	if cmd.usage == "" {
+		cmd.usage = cmd.name
+	}
+	r.commands[cmd.name] = cmd
+	for _, alias := range cmd.aliases {
+		r.aliases[alias] = cmd.name
+	}
+}
+
+// lookup finds a command by name or alias.
+func (r *registry) lookup(name string) (cliCommand, bool) {
+	if target, ok := r.aliases[name]; ok {
+		name = target
+	}
+	cmd, ok := r.commands[name]
+	return cmd, ok
+}
+
+// define adds or replaces a user-defined command. It refuses to shadow a
+// built-in command or alias, or a user command of another kind.
+func (r *registry) define(cmd cliCommand) error {
+	existing, ok := r.lookup(cmd.name)
+	if ok && existing.source == "" {
+		return fmt.Errorf("'%s' is a built-in command", cmd.name)
+	}
+	if ok && existing.source != cmd.source {
+		kind := map[string]string{"alias": "an alias", "macro": "a macro"}[existing.source]
+		return fmt.Errorf("'%s' is already %s", cmd.name, kind)
+	}
+	if cmd.usage == "" {
+		cmd.usage = cmd.name
+	}
+	r.commands[cmd.name] = cmd
+	return nil
+}
+
+// undefine removes a user-defined command of the given source and reports
+// whether there was one.
+func (r *registry) undefine(name, source string) bool {
+	cmd, ok := r.commands[name]
+	if !ok || cmd.source != source {
+		return false
+	}
+	delete(r.commands, name)
+	return true
+}
+
+// clone copies r so a session can define commands of its own.
+func (r *registry) clone() *registry {
+	clone := &registry{
+		commands: make(map[string]cliCommand, len(r.commands)),
+		aliases:  make(map[string]string, len(r.aliases)),
+	}
+	for name, cmd := range r.commands {
+		clone.commands[name] = cmd
+	}
+	for alias, name := range r.aliases {
+		clone.aliases[alias] = name
+	}
+	return clone
+}
+
+// sorted returns every command ordered by name.
+func (r *registry) sorted() []cliCommand {
+	sorted := make([]cliCommand, 0, len(r.commands))
+	for _, cmd := range r.commands {
+		sorted = append(sorted, cmd)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].name < sorted[j].name
+	})
+	return sorted
+}
+
+var commands *registry
+
+func init() {
+	commands = newRegistry(
+		cliCommand{
+			name:        "exit",
+			description: "Exit the Pokedex",
+			aliases:     []string{"quit"},
+			callback:    commandExit,
+		},
+		cliCommand{
+			name:        "help",
+			description: "Displays the help message",
+			usage:       "help [command]",
+			help:        "Without a command, lists every command. With one, shows how to use it.",
+			aliases:     []string{"?"},
+			maxArgs:     1,
+			callback:    commandHelp,
+		},
+		cliCommand{
+			name:        "map",
+			description: "Display locations",
+			help:        "Shows the next page of location areas. Run it again to keep paging.",
+			callback:    commandMap,
+		},
+		cliCommand{
+			name:        "mapb",
+			description: "Previous locations",
+			help:        "Shows the previous page of location areas.",
+			callback:    commandMapb,
+		},
+		cliCommand{
+			name:        "explore",
+			description: "Add area name to show Pokemon found",
+			usage:       "explore <area>",
+			help:        "Lists the Pokemon that can be encountered in a location area, limited to the active game version if one is set.",
+			minArgs:     1,
+			maxArgs:     1,
+			callback:    commmandExplore,
+		},
+		cliCommand{
+			name:        "catch",
+			description: "Attempt to catch Pokemon",
+			usage:       "catch <pokemon>",
+			help:        "Throws a Pokeball. Pokemon with more base experience escape more often. Caught Pokemon are added to your Pokedex.",
+			minArgs:     1,
+			maxArgs:     1,
+			callback:    commandCatch,
+		},
+		cliCommand{
+			name:        "inspect",
+			description: "Show Pokemon details",
+			usage:       "inspect <pokemon> [--sprite|--shiny]",
+			help:        "Shows the height, weight, stats and types of a Pokemon you have caught. --sprite draws it in the terminal and --shiny draws its shiny form.",
+			minArgs:     1,
+			maxArgs:     2,
+			callback:    commandInspect,
+		},
+		cliCommand{
+			name:        "pokedex",
+			description: "List Pokemon you have caught",
+			aliases:     []string{"dex"},
+			callback:    commandPokedex,
+		},
+		cliCommand{
+			name:        "version",
+			description: "Show or set the game version",
+			usage:       "version [name|all]",
+			help:        "Filters explore, moves and where to one game version, e.g. 'version emerald'. 'version all' clears the filter.",
+			maxArgs:     1,
+			callback:    commandVersion,
+		},
+		cliCommand{
+			name:        "moves",
+			description: "List moves a Pokemon learns in the active version",
+			usage:       "moves <pokemon>",
+			minArgs:     1,
+			maxArgs:     1,
+			callback:    commandMoves,
+		},
+		cliCommand{
+			name:        "where",
+			description: "List location areas where a Pokemon can be found",
+			usage:       "where <pokemon>",
+			minArgs:     1,
+			maxArgs:     1,
+			callback:    commandWhere,
+		},
+		cliCommand{
+			name:        "lang",
+			description: "Show or set the display language",
+			usage:       "lang [code|off]",
+			help:        "Shows names and flavor text in a language such as de or ja, falling back to English. 'lang off' shows resource names again.",
+			maxArgs:     1,
+			callback:    commandLang,
+		},
+		cliCommand{
+			name:        "cry",
+			description: "Save a Pokemon cry",
+			usage:       "cry <pokemon>|--all [--legacy] [-o file|dir]",
+			help:        "Downloads a cry as an .ogg file. --all saves the cries of every Pokemon you have caught, --legacy picks the original game cry and -o sets where to save.",
+			minArgs:     1,
+			maxArgs:     4,
+			callback:    commandCry,
+		},
+		cliCommand{
+			name:        "cache",
+			description: "Inspect the response cache",
+			usage:       "cache stats|list|clear|evict <url>",
+			minArgs:     1,
+			maxArgs:     2,
+			callback:    commandCache,
+		},
+		cliCommand{
+			name:        "alias",
+			description: "List, define or remove command aliases",
+			usage:       "alias [name [command args...]] | alias -d <name>",
+			help:        "'alias ex explore' makes 'ex <area>' run 'explore <area>'. Arguments given to an alias are added after its command. Aliases are saved in the config file and can't replace built-in commands.",
+			maxArgs:     anyArgs,
+			callback:    commandAlias,
+		},
+		cliCommand{
+			name:        "macro",
+			description: "List, define or remove command macros",
+			usage:       "macro [name [command; command...]] | macro -d <name>",
+			help:        "'macro hunt explore $1; catch $2; catch $2' makes 'hunt <area> <pokemon>' run those commands in order, stopping at the first error. $1 to $9 are replaced by the macro's arguments and $@ by all of them. Macros are saved in the config file and can't replace built-in commands.",
+			maxArgs:     anyArgs,
+			callback:    commandMacro,
+		},
+		cliCommand{
+			name:        "config",
+			description: "Show or change saved settings",
+			usage:       "config [show] | config set <key> [value]",
+			help:        "Settings are kept in the config file and used every time the Pokedex starts. Flags override them for one session. 'config set theme dark' saves a setting and 'config set theme' goes back to the default.",
+			maxArgs:     anyArgs,
+			callback:    commandConfig,
+		},
+		cliCommand{
+			name:        "sync",
+			description: "Download PokeAPI data for -offline use",
+			usage:       "sync [resource...]",
+			help:        "Saves " + strings.Join(syncResources, ", ") + " to the -dump directory. Name resources to sync only those. Anything already saved is skipped.",
+			maxArgs:     anyArgs,
+			callback:    commandSync,
+		},
+	)
+}
+
+func commandHelp(ctx context.Context, c *config, name ...string) error {
+	if len(name) == 1 {
+		cmd, ok := c.commands.lookup(name[0])
+		if !ok {
+			return fmt.Errorf("unknown command '%s'", name[0])
+		}
+		fmt.Fprintf(c.out, "Usage: %s\n", cmd.usage)
+		fmt.Fprintln(c.out)
+		if cmd.help != "" {
+			fmt.Fprintln(c.out, cmd.help)
+		} else {
+			fmt.Fprintln(c.out, cmd.description)
+		}
+		if len(cmd.aliases) > 0 {
+			fmt.Fprintf(c.out, "Aliases: %s\n", strings.Join(cmd.aliases, ", "))
+		}
+		return nil
+	}
+
+	fmt.Fprintln(c.out, "Welcome to the Pokedex!")
+	fmt.Fprintln(c.out, "Usage:")
+	fmt.Fprintln(c.out)
+	for _, cmd := range c.commands.sorted() {
+		fmt.Fprintf(c.out, "%s: %s\n", cmd.name, cmd.description)
+	}
+	fmt.Fprintln(c.out)
+	fmt.Fprintln(c.out, "Type 'help <command>' for details.")
+	return nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestCheckArgs(t *testing.T) {
+	cases := []struct {
+		input   string
+		wantErr string
+	}{
+		{input: "catch pikachu"},
+		{input: "catch", wantErr: "usage: catch <pokemon>"},
+		{input: "catch pikachu raichu", wantErr: "usage: catch <pokemon>"},
+		{input: "inspect", wantErr: "usage: inspect <pokemon> [--sprite|--shiny]"},
+		{input: "inspect pikachu --shiny"},
+		{input: "map 2", wantErr: "usage: map"},
+		{input: "pokedex"},
+		{input: "sync pokemon move type"},
+		{input: "help catch"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.input, func(t *testing.T) {
+			words := strings.Fields(c.input)
+			cmd, ok := commands.lookup(words[0])
+			if !ok {
+				t.Fatalf("expected to find %s", words[0])
+			}
+			err := cmd.checkArgs(words[1:])
+			if c.wantErr == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if c.wantErr != "" && (err == nil || err.Error() != c.wantErr) {
+				t.Errorf("expected error %q, got %v", c.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	if cmd, ok := commands.lookup("quit"); !ok || cmd.name != "exit" {
+		t.Errorf("expected quit to be an alias of exit, got %+v", cmd)
+	}
+	if _, ok := commands.lookup("fly"); ok {
+		t.Errorf("expected fly to be unknown")
+	}
+
+	sorted := commands.sorted()
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i-1].name >= sorted[i].name {
+			t.Errorf("expected commands in order, got %s before %s", sorted[i-1].name, sorted[i].name)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected registering a clashing alias to panic")
+		}
+	}()
+	newRegistry(cliCommand{name: "exit"}, cliCommand{name: "leave", aliases: []string{"exit"}})
+}
+
+func TestHelpCommand(t *testing.T) {
+	c, _ := newTestConfig(t)
+
+	out, err := runTest(t, c, commandHelp, "dex")
+	want := "Usage: pokedex\n\nList Pokemon you have caught\nAliases: dex\n"
+	if err != nil || out != want {
+		t.Errorf("expected %q, got %q, %v", want, out, err)
+	}
+
+	if _, err := runTest(t, c, commandHelp, "fly"); err == nil || err.Error() != "unknown command 'fly'" {
+		t.Errorf("expected unknown command error, got %v", err)
+	}
+}
+
+func TestUserCommandsPersist(t *testing.T) {
+	c, _ := newTestConfig(t)
+	if _, err := runTest(t, c, commandAlias, "ex", "explore"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runTest(t, c, commandMacro, "hunt", "explore", "$1;", "catch", "$2"); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := loadSettings(c.settingsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Aliases["ex"] != "explore" || strings.Join(s.Macros["hunt"], "|") != "explore $1|catch $2" {
+		t.Errorf("expected ex and hunt to be saved, got %+v", s)
+	}
+
+	// a built-in added after the user defined an alias of the same name
+	// wins, and the alias is reported instead of shadowing it
+	s.Aliases["map"] = "mapb"
+	next, _ := newTestConfig(t)
+	next.settings = s
+	out, _ := runTest(t, next, func(context.Context, *config, ...string) error {
+		registerUserCommands(next)
+		return nil
+	})
+	want := "Warning: alias map ignored: 'map' is a built-in command\n"
+	if out != want {
+		t.Errorf("expected %q, got %q", want, out)
+	}
+	if cmd, ok := next.commands.lookup("hunt"); !ok || cmd.usage != "hunt <arg1> <arg2>" {
+		t.Errorf("expected hunt to be restored, got %+v", cmd)
+	}
+	if cmd, _ := next.commands.lookup("map"); cmd.source != "" {
+		t.Errorf("expected map to stay built-in, got %+v", cmd)
+	}
+}
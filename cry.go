@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+func cryURL(p pokemonDetails, legacy bool) string {
+	if legacy {
+		url, _ := p.Cries.Legacy.(string)
+		return url
+	}
+	return p.Cries.Latest
+}
+
+func cryFileName(name string, legacy bool) string {
+	if legacy {
+		return name + "-legacy.ogg"
+	}
+	return name + ".ogg"
+}
+
+func saveCry(ctx context.Context, c *config, p pokemonDetails, legacy bool, path string) error {
+	url := cryURL(p, legacy)
+	if url == "" {
+		return fmt.Errorf("no cry available for %s", p.Name)
+	}
+
+	data, err := fetch(ctx, c, url)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error creating directory: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("error writing file: %v", err)
+	}
+	fmt.Fprintf(c.out, "Saved %s cry to %s\n", p.Name, path)
+	return nil
+}
+
+func commandCry(ctx context.Context, c *config, name ...string) error {
+	var pokemon, output string
+	legacy, all := false, false
+	for i := 0; i < len(name); i++ {
+		switch arg := name[i]; {
+		case arg == "--legacy":
+			legacy = true
+		case arg == "--all":
+			all = true
+		case arg == "-o":
+			if i+1 >= len(name) {
+				return fmt.Errorf("-o needs a file name")
+			}
+			i++
+			output = name[i]
+		case strings.HasPrefix(arg, "-"):
+			return fmt.Errorf("unknown flag '%s'", arg)
+		case pokemon != "":
+			return fmt.Errorf("please provide one Pokemon name, not '%s' and '%s'", pokemon, arg)
+		default:
+			pokemon = arg
+		}
+	}
+
+	if all {
+		if pokemon != "" {
+			return fmt.Errorf("--all saves every caught Pokemon, leave out '%s'", pokemon)
+		}
+		return exportCries(ctx, c, legacy, output)
+	}
+	if pokemon == "" {
+		return fmt.Errorf("please provide a Pokemon name or --all")
+	}
+
+	item, ok := myPokedex[pokemon]
+	if !ok {
+		var err error
+		item, err = fetchPokemon(ctx, c, pokemon)
+		if err != nil {
+			return err
+		}
+	}
+
+	if output == "" {
+		output = filepath.Join(c.saveDir, cryFileName(item.Name, legacy))
+	}
+	return saveCry(ctx, c, item, legacy, output)
+}
+
+func exportCries(ctx context.Context, c *config, legacy bool, dir string) error {
+	if len(myPokedex) == 0 {
+		fmt.Fprintln(c.out, "You have not caught any Pokemon")
+		return nil
+	}
+	if dir == "" {
+		dir = c.saveDir
+	}
+	if dir == "" {
+		dir = "."
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("error creating directory: %v", err)
+	}
+
+	names := make([]string, 0, len(myPokedex))
+	for key := range myPokedex {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+
+	failed := 0
+	for _, key := range names {
+		path := filepath.Join(dir, cryFileName(myPokedex[key].Name, legacy))
+		if err := saveCry(ctx, c, myPokedex[key], legacy, path); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			fmt.Fprintln(c.out, "Error: ", err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d cries could not be saved", failed, len(names))
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+)
+
+// formats are the output formats of commands that print data.
+var formats = []string{"text", "json"}
+
+func checkFormat(name string) error {
+	if slices.Contains(formats, name) {
+		return nil
+	}
+	return fmt.Errorf("unknown format '%s', use text or json", name)
+}
+
+// writeJSON prints v as indented JSON for the json output format.
+func writeJSON(c *config, v any) error {
+	enc := json.NewEncoder(c.out)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(v)
+}
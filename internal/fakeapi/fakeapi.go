@@ -0,0 +1,115 @@
+// Package fakeapi serves a small fixed slice of PokeAPI over HTTP for tests
+// and demos. Fixtures are stored like an offline dump, so list endpoints
+// paginate like the real API, and anything missing is a 404.
+package fakeapi
+
+import (
+	"embed"
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Lusbox/Pokedex/internal/pokeapi"
+)
+
+//go:embed fixtures
+var fixtures embed.FS
+
+// realBase is the origin fixture URLs point at. It is rewritten to the
+// server's own URL in every response.
+const realBase = "https://pokeapi.co/"
+
+// criesBase and spritesBase are where PokeAPI keeps cries and sprites.
+// They are rewritten too, and the files are served from fixtures/cries and
+// fixtures/sprites.
+const (
+	criesBase   = "https://raw.githubusercontent.com/PokeAPI/cries/main/"
+	spritesBase = "https://raw.githubusercontent.com/PokeAPI/sprites/master/"
+)
+
+type Server struct {
+	*httptest.Server
+	dump     *pokeapi.Dump
+	mu       sync.Mutex
+	latency  time.Duration
+	fail     func(*http.Request) int
+	requests []string
+}
+
+// New starts a server. Close it when done.
+func New() *Server {
+	sub, err := fs.Sub(fixtures, "fixtures")
+	if err != nil {
+		panic(err)
+	}
+	s := &Server{dump: pokeapi.NewDump(sub)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// APIBase returns the server's equivalent of https://pokeapi.co/api/v2/.
+func (s *Server) APIBase() string {
+	return s.URL + "/api/v2/"
+}
+
+// SetLatency delays every response by d, or until the request is
+// cancelled.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// SetFailure calls fail for every request. A non-zero status is sent
+// instead of the fixture. nil removes the hook.
+func (s *Server) SetFailure(fail func(r *http.Request) int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fail = fail
+}
+
+// Requests returns the path and query of every request served so far.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, r.URL.RequestURI())
+	latency, fail := s.latency, s.fail
+	s.mu.Unlock()
+
+	if latency > 0 {
+		timer := time.NewTimer(latency)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if fail != nil {
+		if status := fail(r); status != 0 {
+			w.WriteHeader(status)
+			return
+		}
+	}
+
+	body, err := s.dump.Get(realBase + strings.TrimPrefix(r.URL.RequestURI(), "/"))
+	if errors.Is(err, pokeapi.ErrNotInDump) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(strings.NewReplacer(realBase, s.URL+"/", criesBase, s.URL+"/", spritesBase, s.URL+"/").Replace(string(body))))
+}
@@ -0,0 +1,105 @@
+package fakeapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func get(t *testing.T, ctx context.Context, url string) (int, string, error) {
+	t.Helper()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	return res.StatusCode, string(body), err
+}
+
+func TestServePagination(t *testing.T) {
+	server := New()
+	defer server.Close()
+
+	_, body, err := get(t, context.Background(), server.APIBase()+"location-area/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var page struct {
+		Count   int     `json:"count"`
+		Next    string  `json:"next"`
+		Results []any   `json:"results"`
+		Prev    *string `json:"previous"`
+	}
+	if err := json.Unmarshal([]byte(body), &page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Count != 25 || len(page.Results) != 20 || page.Prev != nil {
+		t.Errorf("unexpected first page: %+v", page)
+	}
+	if want := server.APIBase() + "location-area/?offset=20&limit=20"; page.Next != want {
+		t.Errorf("expected next %q, got %q", want, page.Next)
+	}
+}
+
+func TestServeRewritesURLs(t *testing.T) {
+	server := New()
+	defer server.Close()
+
+	status, body, err := get(t, context.Background(), server.APIBase()+"pokemon/pikachu")
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("expected 200, got %d, %v", status, err)
+	}
+	if strings.Contains(body, "pokeapi.co") || !strings.Contains(body, server.APIBase()+"pokemon/25/encounters") {
+		t.Errorf("expected fixture URLs to point at the server")
+	}
+}
+
+func TestServeNotFound(t *testing.T) {
+	server := New()
+	defer server.Close()
+
+	status, _, err := get(t, context.Background(), server.APIBase()+"pokemon/missingno")
+	if err != nil || status != http.StatusNotFound {
+		t.Errorf("expected 404, got %d, %v", status, err)
+	}
+}
+
+func TestServeHooks(t *testing.T) {
+	server := New()
+	defer server.Close()
+
+	server.SetFailure(func(r *http.Request) int {
+		if strings.Contains(r.URL.Path, "pikachu") {
+			return http.StatusServiceUnavailable
+		}
+		return 0
+	})
+	if status, _, _ := get(t, context.Background(), server.APIBase()+"pokemon/pikachu"); status != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d", status)
+	}
+	if status, _, _ := get(t, context.Background(), server.APIBase()+"location-area/"); status != http.StatusOK {
+		t.Errorf("expected 200, got %d", status)
+	}
+	server.SetFailure(nil)
+
+	server.SetLatency(time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, _, err := get(t, ctx, server.APIBase()+"pokemon/pikachu"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the request to time out, got %v", err)
+	}
+
+	if n := len(server.Requests()); n != 3 {
+		t.Errorf("expected 3 requests, got %d", n)
+	}
+}
@@ -0,0 +1,292 @@
+package pokeapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Lusbox/Pokedex/internal/pokecache"
+)
+
+var ErrNotFound = errors.New("not found")
+
+type Client struct {
+	httpClient  *http.Client
+	cache       *pokecache.Cache
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	Deadline    time.Duration
+	Limiter     *Limiter
+	OnWait      func(time.Duration)
+	sleep       func(context.Context, time.Duration) error
+
+	// StaleWhileRevalidate makes Get answer from stale cache entries
+	// straight away while they are revalidated in the background.
+	StaleWhileRevalidate bool
+
+	// Dump, when set, answers every request instead of the network.
+	Dump *Dump
+
+	// lifetime bounds background revalidations. Close cancels it and
+	// waits for them to finish.
+	lifetime   context.Context
+	stop       context.CancelFunc
+	mu         sync.Mutex
+	background sync.WaitGroup
+	failures   int
+	lastErr    error
+}
+
+func NewClient(cache *pokecache.Cache, timeout time.Duration) *Client {
+	lifetime, stop := context.WithCancel(context.Background())
+	return &Client{
+		httpClient:  &http.Client{Timeout: timeout},
+		cache:       cache,
+		MaxAttempts: 4,
+		BaseDelay:   250 * time.Millisecond,
+		MaxDelay:    5 * time.Second,
+		Deadline:    30 * time.Second,
+		sleep:       sleep,
+		lifetime:    lifetime,
+		stop:        stop,
+	}
+}
+
+// Close cancels background revalidations and waits for them to return.
+// Call it before closing the cache.
+func (c *Client) Close() {
+	c.mu.Lock()
+	c.stop()
+	c.mu.Unlock()
+	c.background.Wait()
+}
+
+// RevalidationErrors returns how many background revalidations failed and
+// the last error, since they have no caller to report to.
+func (c *Client) RevalidationErrors() (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.failures, c.lastErr
+}
+
+// SetTransport replaces the transport used for requests, e.g. with a
+// Recorder.
+func (c *Client) SetTransport(rt http.RoundTripper) {
+	c.httpClient.Transport = rt
+}
+
+// Get returns the body for url from the cache, or fetches it. Concurrent
+// misses for the same url share one fetch, and stale entries are
+// revalidated with a conditional request.
+func (c *Client) Get(ctx context.Context, url string) ([]byte, error) {
+	if c.StaleWhileRevalidate {
+		if entry, ok := c.cache.Lookup(url); ok && entry.Stale {
+			c.revalidate(url)
+			return entry.Val, nil
+		}
+	}
+
+	entry, err := c.cache.GetOrFetchEntry(ctx, url, func(ctx context.Context, stale pokecache.Entry) (pokecache.Entry, error) {
+		return c.fetch(ctx, url, stale, c.OnWait)
+	})
+	return entry.Val, err
+}
+
+// revalidate refreshes url in the background until the client is closed.
+// It doesn't report rate limit waits, which would print over the prompt.
+func (c *Client) revalidate(url string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lifetime.Err() != nil {
+		return
+	}
+	c.background.Add(1)
+	go func() {
+		defer c.background.Done()
+		_, err := c.cache.GetOrFetchEntry(c.lifetime, url, func(ctx context.Context, stale pokecache.Entry) (pokecache.Entry, error) {
+			return c.fetch(ctx, url, stale, nil)
+		})
+		if err != nil && !errors.Is(err, context.Canceled) {
+			c.mu.Lock()
+			c.failures++
+			c.lastErr = fmt.Errorf("%s: %w", url, err)
+			c.mu.Unlock()
+		}
+	}()
+}
+
+// fetch retries network errors, 429s and 5xx responses with jittered
+// exponential backoff until MaxAttempts or Deadline is reached or ctx is
+// cancelled. Deadline covers the requests as well as the waits between
+// them. With a Dump set it reads from the dump instead. onWait, if not
+// nil, is told about every rate limit wait.
+func (c *Client) fetch(ctx context.Context, url string, stale pokecache.Entry, onWait func(time.Duration)) (pokecache.Entry, error) {
+	if c.Dump != nil {
+		body, err := c.Dump.Get(url)
+		return pokecache.Entry{Val: body}, err
+	}
+
+	deadline := time.Now().Add(c.Deadline)
+	attemptCtx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+
+	var lastErr error
+	attempt := 1
+	for ; ; attempt++ {
+		entry, retryAfter, err := c.do(attemptCtx, url, stale, onWait)
+		if err == nil {
+			return entry, nil
+		}
+		if ctx.Err() == nil && attemptCtx.Err() != nil {
+			if lastErr == nil {
+				lastErr = fmt.Errorf("no response within %v", c.Deadline)
+			}
+			break
+		}
+		var retryErr *retryableError
+		if !errors.As(err, &retryErr) {
+			return pokecache.Entry{}, err
+		}
+		lastErr = retryErr.err
+
+		if attempt >= c.MaxAttempts {
+			break
+		}
+		delay := retryAfter
+		if delay == 0 {
+			delay = c.backoff(attempt)
+		}
+		if time.Now().Add(delay).After(deadline) {
+			break
+		}
+		if err := c.sleep(ctx, delay); err != nil {
+			return pokecache.Entry{}, err
+		}
+	}
+
+	if attempt == 1 {
+		return pokecache.Entry{}, lastErr
+	}
+	return pokecache.Entry{}, fmt.Errorf("%v (after %d attempts)", lastErr, attempt)
+}
+
+type retryableError struct {
+	err error
+}
+
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (c *Client) do(ctx context.Context, url string, stale pokecache.Entry, onWait func(time.Duration)) (pokecache.Entry, time.Duration, error) {
+	if c.Limiter != nil {
+		if wait := c.Limiter.Reserve(); wait > 0 {
+			if onWait != nil {
+				onWait(wait)
+			}
+			if err := c.sleep(ctx, wait); err != nil {
+				return pokecache.Entry{}, 0, err
+			}
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return pokecache.Entry{}, 0, fmt.Errorf("error creating request: %v", err)
+	}
+	if stale.ETag != "" {
+		req.Header.Set("If-None-Match", stale.ETag)
+	}
+	if stale.LastModified != "" {
+		req.Header.Set("If-Modified-Since", stale.LastModified)
+	}
+	res, err := c.httpClient.Do(req)
+	if ctx.Err() != nil {
+		return pokecache.Entry{}, 0, ctx.Err()
+	}
+	if errors.Is(err, ErrNotRecorded) {
+		return pokecache.Entry{}, 0, err
+	}
+	if err != nil {
+		return pokecache.Entry{}, 0, &retryableError{fmt.Errorf("error getting response: %v", err)}
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if ctx.Err() != nil {
+		return pokecache.Entry{}, 0, ctx.Err()
+	}
+	if err != nil {
+		return pokecache.Entry{}, 0, &retryableError{fmt.Errorf("error reading body: %v", err)}
+	}
+
+	entry := pokecache.Entry{
+		Val:          body,
+		ETag:         res.Header.Get("ETag"),
+		LastModified: res.Header.Get("Last-Modified"),
+	}
+
+	switch {
+	case res.StatusCode == http.StatusNotModified && stale.Val != nil:
+		entry.Val = stale.Val
+		if entry.ETag == "" {
+			entry.ETag = stale.ETag
+		}
+		if entry.LastModified == "" {
+			entry.LastModified = stale.LastModified
+		}
+		return entry, 0, nil
+	case res.StatusCode == http.StatusNotFound:
+		return pokecache.Entry{}, 0, ErrNotFound
+	case res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500:
+		err := fmt.Errorf("error with statuscode: %v", res.StatusCode)
+		return pokecache.Entry{}, retryAfter(res.Header.Get("Retry-After")), &retryableError{err}
+	case res.StatusCode > 299:
+		return pokecache.Entry{}, 0, fmt.Errorf("error with statuscode: %v", res.StatusCode)
+	}
+	return entry, 0, nil
+}
+
+func (c *Client) backoff(attempt int) time.Duration {
+	delay := c.BaseDelay << (attempt - 1)
+	if delay > c.MaxDelay || delay <= 0 {
+		delay = c.MaxDelay
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// retryAfter parses a Retry-After header given either in seconds or as an
+// HTTP date. It returns 0 when the header is missing or invalid.
+func retryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay
+		}
+	}
+	return 0
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,445 @@
+package pokeapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Lusbox/Pokedex/internal/pokecache"
+)
+
+func newTestClient(t *testing.T, interval time.Duration) (*Client, *[]time.Duration) {
+	cache := pokecache.NewCache(interval)
+	t.Cleanup(cache.Close)
+	client := NewClient(cache, time.Second)
+	client.BaseDelay = time.Millisecond
+	client.MaxDelay = 10 * time.Millisecond
+	delays := &[]time.Duration{}
+	client.sleep = func(ctx context.Context, d time.Duration) error {
+		*delays = append(*delays, d)
+		return nil
+	}
+	return client, delays
+}
+
+// failingServer responds with status to the first failures requests and
+// with body afterwards.
+func failingServer(failures int32, status int, header http.Header, body string) (*httptest.Server, *int32) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) <= failures {
+			for key, values := range header {
+				w.Header()[key] = values
+			}
+			w.WriteHeader(status)
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+	return server, &requests
+}
+
+func TestGetRetries(t *testing.T) {
+	cases := []struct {
+		failures int32
+		status   int
+		wantErr  string
+		wantReqs int32
+	}{
+		{failures: 0, status: 500, wantReqs: 1},
+		{failures: 2, status: 500, wantReqs: 3},
+		{failures: 3, status: 502, wantReqs: 4},
+		{failures: 4, status: 503, wantErr: "error with statuscode: 503 (after 4 attempts)", wantReqs: 4},
+		{failures: 1, status: 400, wantErr: "error with statuscode: 400", wantReqs: 1},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Test case %v", i), func(t *testing.T) {
+			server, requests := failingServer(c.failures, c.status, nil, "pikachu")
+			defer server.Close()
+			client, _ := newTestClient(t, time.Minute)
+
+			body, err := client.Get(context.Background(), server.URL)
+			if c.wantErr != "" {
+				if err == nil || err.Error() != c.wantErr {
+					t.Errorf("expected error %q, got %v", c.wantErr, err)
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			} else if string(body) != "pikachu" {
+				t.Errorf("expected body pikachu, got %s", body)
+			}
+			if *requests != c.wantReqs {
+				t.Errorf("expected %d requests, got %d", c.wantReqs, *requests)
+			}
+		})
+	}
+}
+
+func TestGetNotFound(t *testing.T) {
+	server, requests := failingServer(1, http.StatusNotFound, nil, "")
+	defer server.Close()
+	client, _ := newTestClient(t, time.Minute)
+
+	_, err := client.Get(context.Background(), server.URL)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if *requests != 1 {
+		t.Errorf("expected 1 request, got %d", *requests)
+	}
+}
+
+func TestGetRetryAfter(t *testing.T) {
+	header := http.Header{"Retry-After": []string{"2"}}
+	server, _ := failingServer(1, http.StatusTooManyRequests, header, "pikachu")
+	defer server.Close()
+	client, delays := newTestClient(t, time.Minute)
+
+	if _, err := client.Get(context.Background(), server.URL); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(*delays) != 1 || (*delays)[0] != 2*time.Second {
+		t.Errorf("expected a single 2s delay, got %v", *delays)
+	}
+}
+
+func TestGetDeadline(t *testing.T) {
+	header := http.Header{"Retry-After": []string{"60"}}
+	server, requests := failingServer(5, http.StatusServiceUnavailable, header, "pikachu")
+	defer server.Close()
+	client, _ := newTestClient(t, time.Minute)
+	client.Deadline = time.Second
+
+	_, err := client.Get(context.Background(), server.URL)
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("expected 503 error, got %v", err)
+	}
+	if *requests != 1 {
+		t.Errorf("expected 1 request, got %d", *requests)
+	}
+}
+
+func TestGetDeadlineSlowResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	client, _ := newTestClient(t, time.Minute)
+	client.Deadline = 50 * time.Millisecond
+
+	start := time.Now()
+	_, err := client.Get(context.Background(), server.URL)
+	if err == nil || err.Error() != "no response within 50ms" {
+		t.Errorf("expected the deadline to stop the request, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expected to give up at the deadline, took %v", elapsed)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	client, _ := newTestClient(t, time.Minute)
+	for attempt := 1; attempt <= 6; attempt++ {
+		max := min(client.BaseDelay<<(attempt-1), client.MaxDelay)
+		delay := client.backoff(attempt)
+		if delay < max/2 || delay > max {
+			t.Errorf("attempt %d: delay %v outside [%v, %v]", attempt, delay, max/2, max)
+		}
+	}
+}
+
+func TestGetUsesCache(t *testing.T) {
+	server, requests := failingServer(0, 0, nil, "pikachu")
+	defer server.Close()
+	client, _ := newTestClient(t, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.Get(context.Background(), server.URL); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if *requests != 1 {
+		t.Errorf("expected 1 request, got %d", *requests)
+	}
+}
+
+func TestGetRateLimit(t *testing.T) {
+	server, requests := failingServer(0, 0, nil, "pikachu")
+	defer server.Close()
+	client, delays := newTestClient(t, time.Minute)
+	client.Limiter = NewLimiter(1, 1)
+	waits := 0
+	client.OnWait = func(time.Duration) { waits++ }
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.Get(context.Background(), server.URL); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if waits != 0 || len(*delays) != 0 {
+		t.Errorf("expected cache hits not to be throttled, got %d waits", waits)
+	}
+
+	if _, err := client.Get(context.Background(), server.URL+"/other"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if waits != 1 || len(*delays) != 1 {
+		t.Errorf("expected one throttled request, got %d waits", waits)
+	}
+	if *requests != 2 {
+		t.Errorf("expected 2 requests, got %d", *requests)
+	}
+}
+
+func TestGetCancelled(t *testing.T) {
+	server, requests := failingServer(10, http.StatusServiceUnavailable, nil, "pikachu")
+	defer server.Close()
+	client, _ := newTestClient(t, time.Minute)
+	client.sleep = sleep
+	client.BaseDelay = time.Hour
+	client.MaxDelay = time.Hour
+	client.Deadline = 2 * time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		for atomic.LoadInt32(requests) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+	}()
+
+	_, err := client.Get(ctx, server.URL)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if *requests != 1 {
+		t.Errorf("expected 1 request, got %d", *requests)
+	}
+}
+
+// etagServer serves body with an ETag and answers matching conditional
+// requests with 304 Not Modified.
+func etagServer(body string) (*httptest.Server, *int32, *int32) {
+	var requests, notModified int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			atomic.AddInt32(&notModified, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+	return server, &requests, &notModified
+}
+
+func TestGetRevalidates(t *testing.T) {
+	server, requests, notModified := etagServer("pikachu")
+	defer server.Close()
+	client, _ := newTestClient(t, 5*time.Millisecond)
+
+	for i := 0; i < 2; i++ {
+		body, err := client.Get(context.Background(), server.URL)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(body) != "pikachu" {
+			t.Errorf("expected body pikachu, got %s", body)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if atomic.LoadInt32(requests) != 2 || atomic.LoadInt32(notModified) != 1 {
+		t.Errorf("expected 1 full and 1 conditional request, got %d requests, %d not modified", *requests, *notModified)
+	}
+	if _, ok := client.cache.Get(server.URL); ok {
+		t.Errorf("expected entry to be stale again")
+	}
+}
+
+func TestGetStaleWhileRevalidate(t *testing.T) {
+	server, requests, notModified := etagServer("pikachu")
+	defer server.Close()
+	client, _ := newTestClient(t, 5*time.Millisecond)
+	client.StaleWhileRevalidate = true
+
+	if _, err := client.Get(context.Background(), server.URL); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	body, err := client.Get(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(body) != "pikachu" {
+		t.Errorf("expected stale body pikachu, got %s", body)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(notModified) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if atomic.LoadInt32(requests) != 2 || atomic.LoadInt32(notModified) != 1 {
+		t.Errorf("expected a background revalidation, got %d requests, %d not modified", *requests, *notModified)
+	}
+}
+
+func TestRevalidateErrors(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) > 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		// an ETag keeps the stale entry from being reaped
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, "pikachu")
+	}))
+	defer server.Close()
+	client, _ := newTestClient(t, 5*time.Millisecond)
+	client.StaleWhileRevalidate = true
+	client.Limiter = NewLimiter(0.001, 1)
+	waits := 0
+	client.OnWait = func(time.Duration) { waits++ }
+
+	if _, err := client.Get(context.Background(), server.URL); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if _, err := client.Get(context.Background(), server.URL); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for n, _ := client.RevalidationErrors(); n == 0 && time.Now().Before(deadline); n, _ = client.RevalidationErrors() {
+		time.Sleep(time.Millisecond)
+	}
+	client.Close()
+
+	if waits != 0 {
+		t.Errorf("expected background requests not to report waits, got %d", waits)
+	}
+	n, err := client.RevalidationErrors()
+	if want := server.URL + ": error with statuscode: 400"; n != 1 || err == nil || err.Error() != want {
+		t.Errorf("expected 1 error %q, got %d, %v", want, n, err)
+	}
+}
+
+func TestCloseCancelsRevalidation(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) > 1 {
+			<-r.Context().Done()
+			return
+		}
+		// an ETag keeps the stale entry from being reaped
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, "pikachu")
+	}))
+	defer server.Close()
+	client, _ := newTestClient(t, 5*time.Millisecond)
+	client.StaleWhileRevalidate = true
+
+	if _, err := client.Get(context.Background(), server.URL); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if _, err := client.Get(context.Background(), server.URL); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for atomic.LoadInt32(&requests) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		client.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatalf("expected Close to cancel the revalidation")
+	}
+	if n, _ := client.RevalidationErrors(); n != 0 {
+		t.Errorf("expected a cancelled revalidation not to count as an error, got %d", n)
+	}
+}
+
+// policyClock is a pokecache.Clock that only moves when told to. Its
+// tickers never fire, so nothing is reaped during the test.
+type policyClock struct {
+	now time.Time
+}
+
+func (c *policyClock) Now() time.Time {
+	return c.now
+}
+
+func (c *policyClock) NewTicker(d time.Duration) pokecache.Ticker {
+	return policyTicker{}
+}
+
+type policyTicker struct{}
+
+func (policyTicker) C() <-chan time.Time { return nil }
+func (policyTicker) Stop()               {}
+
+func TestCachePolicies(t *testing.T) {
+	const defaultTTL = 5 * time.Minute
+	cases := []struct {
+		url  string
+		ttl  time.Duration
+		want time.Duration
+	}{
+		{url: "https://pokeapi.co/api/v2/location-area/", want: defaultTTL},
+		{url: "https://pokeapi.co/api/v2/location-area/?offset=20&limit=20", want: defaultTTL},
+		{url: "https://pokeapi.co/api/v2/location-area/?offset=20&limit=20", ttl: 10 * time.Minute, want: 10 * time.Minute},
+		{url: "https://pokeapi.co/api/v2/pokemon-species/pikachu", want: 24 * time.Hour},
+		{url: "https://pokeapi.co/api/v2/pokemon-species/pikachu", ttl: 10 * time.Minute, want: 24 * time.Hour},
+		{url: "https://pokeapi.co/api/v2/pokemon/25/encounters", want: 24 * time.Hour},
+		{url: "https://raw.githubusercontent.com/PokeAPI/sprites/master/sprites/pokemon/25.png", want: pokecache.NeverExpire},
+		{url: "https://example.com/other", want: defaultTTL},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Test case %v", i), func(t *testing.T) {
+			clock := &policyClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+			opts := []pokecache.Option{pokecache.WithPolicies(CachePolicies...), pokecache.WithClock(clock)}
+			if c.ttl != 0 {
+				opts = append(opts, pokecache.WithTTL(c.ttl))
+			}
+			cache := pokecache.NewCache(defaultTTL, opts...)
+			defer cache.Close()
+			cache.Add(c.url, []byte("body"))
+
+			if c.want == pokecache.NeverExpire {
+				clock.now = clock.now.Add(365 * 24 * time.Hour)
+				if entry, ok := cache.Lookup(c.url); !ok || entry.Stale {
+					t.Errorf("expected entry to stay fresh, got ok=%v stale=%v", ok, entry.Stale)
+				}
+				return
+			}
+
+			clock.now = clock.now.Add(c.want)
+			if entry, ok := cache.Lookup(c.url); !ok || entry.Stale {
+				t.Errorf("expected entry to be fresh after %v, got ok=%v stale=%v", c.want, ok, entry.Stale)
+			}
+			clock.now = clock.now.Add(time.Second)
+			if entry, ok := cache.Lookup(c.url); !ok || !entry.Stale {
+				t.Errorf("expected entry to be stale after %v, got ok=%v stale=%v", c.want+time.Second, ok, entry.Stale)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package pokeapi
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var ErrNotInDump = errors.New("not in the offline dump")
+
+// defaultPageSize is what PokeAPI returns when a list URL has no limit.
+const defaultPageSize = 20
+
+// Dump serves PokeAPI responses from a local copy laid out like the URL
+// paths, e.g. api/v2/pokemon/pikachu/index.json. Lists are stored whole in
+// api/v2/<resource>/index.json and paginated on the fly.
+type Dump struct {
+	fsys   fs.FS
+	closer io.Closer
+}
+
+// NewDump serves the dump in fsys, such as an embedded one.
+func NewDump(fsys fs.FS) *Dump {
+	return &Dump{fsys: fsys}
+}
+
+// OpenDump opens a dump directory or a zip archive of one.
+func OpenDump(root string) (*Dump, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, fmt.Errorf("error opening dump: %v", err)
+	}
+	if info.IsDir() {
+		return NewDump(os.DirFS(root)), nil
+	}
+
+	archive, err := zip.OpenReader(root)
+	if err != nil {
+		return nil, fmt.Errorf("error opening dump: %v", err)
+	}
+	return &Dump{fsys: archive, closer: archive}, nil
+}
+
+func (d *Dump) Close() error {
+	if d.closer == nil {
+		return nil
+	}
+	return d.closer.Close()
+}
+
+// Get returns the stored body for rawURL. Resources missing from the dump
+// give an error wrapping ErrNotInDump.
+func (d *Dump) Get(rawURL string) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing url: %v", err)
+	}
+	name := DumpPath(u)
+	body, err := fs.ReadFile(d.fsys, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%s is %w", strings.TrimSuffix(name, "/index.json"), ErrNotInDump)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading dump: %v", err)
+	}
+	if isList(u) {
+		return paginate(u, body)
+	}
+	return body, nil
+}
+
+// DumpPath returns where the response for u lives inside a dump. Files
+// such as sprites keep their name, everything else is an index.json in a
+// directory named after the path.
+func DumpPath(u *url.URL) string {
+	name := strings.Trim(path.Clean("/"+u.Path), "/")
+	if path.Ext(name) != "" {
+		return name
+	}
+	return path.Join(name, "index.json")
+}
+
+// WriteDump stores body as the response for rawURL in the dump directory
+// root.
+func WriteDump(root, rawURL string, body []byte) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("error parsing url: %v", err)
+	}
+	name := filepath.Join(root, filepath.FromSlash(DumpPath(u)))
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return fmt.Errorf("error creating directory: %v", err)
+	}
+	if err := os.WriteFile(name, body, 0644); err != nil {
+		return fmt.Errorf("error writing file: %v", err)
+	}
+	return nil
+}
+
+func isList(u *url.URL) bool {
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	return len(parts) == 3 && parts[0] == "api"
+}
+
+type listPage struct {
+	Count    int               `json:"count"`
+	Next     *string           `json:"next"`
+	Previous *string           `json:"previous"`
+	Results  []json.RawMessage `json:"results"`
+}
+
+// paginate cuts the page u asks for out of a full list, linking the next
+// and previous pages the way PokeAPI does.
+func paginate(u *url.URL, body []byte) ([]byte, error) {
+	var list listPage
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, fmt.Errorf("error parsing dump: %v", err)
+	}
+
+	query := u.Query()
+	offset, _ := strconv.Atoi(query.Get("offset"))
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = defaultPageSize
+	}
+	offset = min(max(offset, 0), len(list.Results))
+	end := min(offset+limit, len(list.Results))
+
+	page := listPage{
+		Count:   len(list.Results),
+		Results: list.Results[offset:end],
+	}
+	if end < len(list.Results) {
+		page.Next = pageURL(u, end, limit)
+	}
+	if offset > 0 {
+		page.Previous = pageURL(u, max(offset-limit, 0), limit)
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(page); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func pageURL(u *url.URL, offset, limit int) *string {
+	next := *u
+	next.RawQuery = fmt.Sprintf("offset=%d&limit=%d", offset, limit)
+	s := next.String()
+	return &s
+}
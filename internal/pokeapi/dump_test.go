@@ -0,0 +1,167 @@
+package pokeapi
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testAPI = "https://pokeapi.co/api/v2/"
+
+// newTestDump writes a location-area list with n areas and one pokemon to
+// a dump directory.
+func newTestDump(t *testing.T, n int) string {
+	root := t.TempDir()
+	results := []string{}
+	for i := 0; i < n; i++ {
+		results = append(results, fmt.Sprintf(`{"name":"area-%d","url":"%slocation-area/%d/"}`, i, testAPI, i))
+	}
+	list := fmt.Sprintf(`{"count":%d,"next":null,"previous":null,"results":[%s]}`, n, strings.Join(results, ","))
+	if err := WriteDump(root, testAPI+"location-area/?limit=100000", []byte(list)); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteDump(root, testAPI+"pokemon/pikachu", []byte(`{"name":"pikachu"}`)); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestDumpGet(t *testing.T) {
+	dump, err := OpenDump(newTestDump(t, 45))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dump.Close()
+
+	for _, url := range []string{testAPI + "pokemon/pikachu", testAPI + "pokemon/pikachu/"} {
+		body, err := dump.Get(url)
+		if err != nil || string(body) != `{"name":"pikachu"}` {
+			t.Errorf("%s: expected pikachu, got %s, %v", url, body, err)
+		}
+	}
+
+	_, err = dump.Get(testAPI + "pokemon/missingno")
+	if !errors.Is(err, ErrNotInDump) {
+		t.Errorf("expected ErrNotInDump, got %v", err)
+	}
+	if err == nil || !strings.Contains(err.Error(), "api/v2/pokemon/missingno") {
+		t.Errorf("expected the error to name the resource, got %v", err)
+	}
+}
+
+func TestDumpPaginates(t *testing.T) {
+	dump, err := OpenDump(newTestDump(t, 45))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dump.Close()
+
+	cases := []struct {
+		url      string
+		first    string
+		size     int
+		next     string
+		previous string
+	}{
+		{
+			url:   testAPI + "location-area/",
+			first: "area-0",
+			size:  20,
+			next:  testAPI + "location-area/?offset=20&limit=20",
+		},
+		{
+			url:      testAPI + "location-area/?offset=20&limit=20",
+			first:    "area-20",
+			size:     20,
+			next:     testAPI + "location-area/?offset=40&limit=20",
+			previous: testAPI + "location-area/?offset=0&limit=20",
+		},
+		{
+			url:      testAPI + "location-area/?offset=40&limit=20",
+			first:    "area-40",
+			size:     5,
+			previous: testAPI + "location-area/?offset=20&limit=20",
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Test case %v", i), func(t *testing.T) {
+			body, err := dump.Get(c.url)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var page struct {
+				Count    int    `json:"count"`
+				Next     string `json:"next"`
+				Previous string `json:"previous"`
+				Results  []struct {
+					Name string `json:"name"`
+				} `json:"results"`
+			}
+			if err := json.Unmarshal(body, &page); err != nil {
+				t.Fatal(err)
+			}
+			if page.Count != 45 || len(page.Results) != c.size || page.Results[0].Name != c.first {
+				t.Errorf("unexpected page: %+v", page)
+			}
+			if page.Next != c.next || page.Previous != c.previous {
+				t.Errorf("expected next %q and previous %q, got %q and %q", c.next, c.previous, page.Next, page.Previous)
+			}
+		})
+	}
+}
+
+func TestDumpArchive(t *testing.T) {
+	root := newTestDump(t, 3)
+	archive := filepath.Join(t.TempDir(), "dump.zip")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := zip.NewWriter(f)
+	if err := w.AddFS(os.DirFS(root)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	dump, err := OpenDump(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dump.Close()
+	if body, err := dump.Get(testAPI + "pokemon/pikachu"); err != nil || string(body) != `{"name":"pikachu"}` {
+		t.Errorf("expected pikachu, got %s, %v", body, err)
+	}
+}
+
+func TestGetOffline(t *testing.T) {
+	server, requests := failingServer(0, 0, nil, "pikachu")
+	server.Close()
+	dump, err := OpenDump(newTestDump(t, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dump.Close()
+	client, _ := newTestClient(t, time.Minute)
+	client.Dump = dump
+
+	if _, err := client.Get(context.Background(), testAPI+"pokemon/pikachu"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := client.Get(context.Background(), server.URL); !errors.Is(err, ErrNotInDump) {
+		t.Errorf("expected ErrNotInDump, got %v", err)
+	}
+	if *requests != 0 {
+		t.Errorf("expected no requests, got %d", *requests)
+	}
+}
@@ -0,0 +1,40 @@
+package pokeapi
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestLimiterReserve(t *testing.T) {
+	start := time.Now()
+	now := start
+	limiter := NewLimiter(2, 3)
+	limiter.now = func() time.Time { return now }
+	limiter.last = start
+
+	cases := []struct {
+		at   time.Duration
+		want time.Duration
+	}{
+		{at: 0, want: 0},
+		{at: 0, want: 0},
+		{at: 0, want: 0},
+		{at: 0, want: 500 * time.Millisecond},
+		{at: 0, want: time.Second},
+		{at: 2 * time.Second, want: 0},
+		{at: 10 * time.Second, want: 0},
+		{at: 10 * time.Second, want: 0},
+		{at: 10 * time.Second, want: 0},
+		{at: 10 * time.Second, want: 500 * time.Millisecond},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Test case %v", i), func(t *testing.T) {
+			now = start.Add(c.at)
+			if got := limiter.Reserve(); got != c.want {
+				t.Errorf("expected wait %v, got %v", c.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package pokeapi
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/Lusbox/Pokedex/internal/pokecache"
+)
+
+// CachePolicies maps PokeAPI resource kinds to cache lifetimes. Sprite and
+// cry files never change once published and named resources change
+// rarely. Paginated lists, which shift as resources are added, and other
+// URLs fall through to the cache's default TTL, so WithTTL sets how long
+// they are kept.
+var CachePolicies = []pokecache.Policy{
+	{
+		Pattern: regexp.MustCompile(`^https://raw\.githubusercontent\.com/PokeAPI/`),
+		TTL:     pokecache.NeverExpire,
+	},
+	{
+		Pattern: regexp.MustCompile(`/api/v2/[a-z-]+/[^/?]+`),
+		TTL:     24 * time.Hour,
+	},
+}
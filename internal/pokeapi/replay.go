@@ -0,0 +1,121 @@
+package pokeapi
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var ErrNotRecorded = errors.New("no recorded response")
+
+// Environment variables that switch the fetch path to recorded responses.
+// POKEDEX_HTTP is "record" or "replay" and POKEDEX_HTTP_DIR is where the
+// responses are kept.
+const (
+	ModeEnv = "POKEDEX_HTTP"
+	DirEnv  = "POKEDEX_HTTP_DIR"
+)
+
+const defaultFixturesDir = "fixtures"
+
+// Recorder is an http.RoundTripper that saves every response it gets from
+// Transport to Dir, or with Replay set, answers from Dir without touching
+// the network. Responses are stored as raw HTTP, one file per URL, so they
+// can be read, edited and attached to bug reports.
+type Recorder struct {
+	Dir       string
+	Replay    bool
+	Transport http.RoundTripper
+}
+
+// RecorderFromEnv returns the Recorder configured by ModeEnv and DirEnv,
+// or nil if ModeEnv is unset.
+func RecorderFromEnv(getenv func(string) string) (*Recorder, error) {
+	dir := getenv(DirEnv)
+	if dir == "" {
+		dir = defaultFixturesDir
+	}
+	switch mode := getenv(ModeEnv); mode {
+	case "":
+		return nil, nil
+	case "record":
+		return &Recorder{Dir: dir, Transport: http.DefaultTransport}, nil
+	case "replay":
+		return &Recorder{Dir: dir, Replay: true}, nil
+	default:
+		return nil, fmt.Errorf("unknown %s mode '%s', use record or replay", ModeEnv, mode)
+	}
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	path := filepath.Join(r.Dir, fixtureName(req))
+	if r.Replay {
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w (record it with %s=record)", ErrNotRecorded, ModeEnv)
+		}
+		if err != nil {
+			return nil, err
+		}
+		res, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), req)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %v", path, err)
+		}
+		return res, nil
+	}
+
+	res, err := r.Transport.RoundTrip(req)
+	if err != nil || !recordable(res.StatusCode) {
+		return res, err
+	}
+	dump, err := httputil.DumpResponse(res, true)
+	if err != nil {
+		res.Body.Close()
+		return nil, err
+	}
+	if err := os.MkdirAll(r.Dir, 0755); err != nil {
+		res.Body.Close()
+		return nil, fmt.Errorf("error creating directory: %v", err)
+	}
+	if err := os.WriteFile(path, dump, 0644); err != nil {
+		res.Body.Close()
+		return nil, fmt.Errorf("error writing file: %v", err)
+	}
+	return res, nil
+}
+
+// recordable reports whether a response with status is worth replaying.
+// Only answers the client keeps are: successes and 404s. Failures it would
+// retry must not replace a good recording, and a 304 has no body, so the
+// earlier recording is kept.
+func recordable(status int) bool {
+	return status >= 200 && status <= 299 || status == http.StatusNotFound
+}
+
+// fixtureName turns a request URL into a flat, readable file name such as
+// pokeapi.co_api_v2_pokemon_pikachu-1a2b3c4d.http. Flattening can map
+// different URLs to the same name, so a short hash of the URL keeps them
+// apart.
+func fixtureName(req *http.Request) string {
+	name := strings.Trim(req.URL.Host+req.URL.Path, "/")
+	if req.URL.RawQuery != "" {
+		name += "_" + req.URL.RawQuery
+	}
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '.', r == '=':
+			return r
+		}
+		return '_'
+	}, name)
+	sum := sha256.Sum256([]byte(req.URL.String()))
+	return name + "-" + hex.EncodeToString(sum[:4]) + ".http"
+}
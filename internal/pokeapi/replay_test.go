@@ -0,0 +1,138 @@
+package pokeapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordReplay(t *testing.T) {
+	dir := t.TempDir()
+	// larger than a read buffer, so the replayed body must outlive the file
+	want := strings.Repeat("pikachu", 1000)
+	server, requests := failingServer(0, 0, nil, want)
+
+	recorder, _ := newTestClient(t, time.Minute)
+	recorder.SetTransport(&Recorder{Dir: dir, Transport: http.DefaultTransport})
+	if body, err := recorder.Get(context.Background(), server.URL+"/api/v2/pokemon/pikachu"); err != nil || string(body) != want {
+		t.Fatalf("expected pikachu, got %.20s, %v", body, err)
+	}
+	server.Close()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*_api_v2_pokemon_pikachu-*.http"))
+	if len(files) != 1 {
+		t.Fatalf("expected one recorded response, got %v", files)
+	}
+
+	replayer, _ := newTestClient(t, time.Minute)
+	replayer.SetTransport(&Recorder{Dir: dir, Replay: true})
+	if body, err := replayer.Get(context.Background(), server.URL+"/api/v2/pokemon/pikachu"); err != nil || string(body) != want {
+		t.Errorf("expected replayed pikachu, got %.20s, %v", body, err)
+	}
+
+	_, err := replayer.Get(context.Background(), server.URL+"/api/v2/pokemon/raichu")
+	if !errors.Is(err, ErrNotRecorded) {
+		t.Errorf("expected ErrNotRecorded, got %v", err)
+	}
+	if *requests != 1 {
+		t.Errorf("expected 1 request, got %d", *requests)
+	}
+}
+
+func TestRecorderFromEnv(t *testing.T) {
+	cases := []struct {
+		env     map[string]string
+		want    *Recorder
+		wantErr bool
+	}{
+		{env: map[string]string{}},
+		{env: map[string]string{ModeEnv: "replay"}, want: &Recorder{Dir: "fixtures", Replay: true}},
+		{env: map[string]string{ModeEnv: "replay", DirEnv: "bug-123"}, want: &Recorder{Dir: "bug-123", Replay: true}},
+		{env: map[string]string{ModeEnv: "record"}, want: &Recorder{Dir: "fixtures", Transport: http.DefaultTransport}},
+		{env: map[string]string{ModeEnv: "rewind"}, wantErr: true},
+	}
+
+	for _, c := range cases {
+		got, err := RecorderFromEnv(func(key string) string { return c.env[key] })
+		if (err != nil) != c.wantErr {
+			t.Errorf("%v: unexpected error %v", c.env, err)
+		}
+		if (got == nil) != (c.want == nil) || got != nil && *got != *c.want {
+			t.Errorf("%v: expected %+v, got %+v", c.env, c.want, got)
+		}
+	}
+}
+
+func TestRecordKeepsEarlierResponse(t *testing.T) {
+	dir := t.TempDir()
+	server, _, notModified := etagServer("pikachu")
+	defer server.Close()
+	client, _ := newTestClient(t, 5*time.Millisecond)
+	client.SetTransport(&Recorder{Dir: dir, Transport: http.DefaultTransport})
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.Get(context.Background(), server.URL); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if *notModified != 1 {
+		t.Fatalf("expected a conditional request, got %d", *notModified)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.http"))
+	if len(files) != 1 {
+		t.Fatalf("expected one recorded response, got %v", files)
+	}
+	data, _ := os.ReadFile(files[0])
+	if !strings.Contains(string(data), "200 OK") || !strings.Contains(string(data), "pikachu") {
+		t.Errorf("expected the 200 response to be kept, got %s", data)
+	}
+}
+
+func TestRecordSkipsFailures(t *testing.T) {
+	dir := t.TempDir()
+	server, _ := failingServer(2, http.StatusServiceUnavailable, nil, "pikachu")
+	defer server.Close()
+	client, _ := newTestClient(t, time.Minute)
+	client.SetTransport(&Recorder{Dir: dir, Transport: http.DefaultTransport})
+
+	if _, err := client.Get(context.Background(), server.URL+"/api/v2/pokemon/pikachu"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.http"))
+	if len(files) != 1 {
+		t.Fatalf("expected one recorded response, got %v", files)
+	}
+	data, _ := os.ReadFile(files[0])
+	if !strings.Contains(string(data), "200 OK") {
+		t.Errorf("expected only the final 200 to be recorded, got %s", data)
+	}
+}
+
+func TestFixtureNameCollisions(t *testing.T) {
+	urls := []string{
+		"https://pokeapi.co/api/v2/pokemon/mr-mime",
+		"https://pokeapi.co/api/v2/pokemon_mr-mime",
+		"https://pokeapi.co/api/v2/pokemon/mr_mime",
+		"https://pokeapi.co/api/v2/pokemon/?limit=20",
+		"https://pokeapi.co/api/v2/pokemon_limit=20",
+	}
+	seen := map[string]string{}
+	for _, url := range urls {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := fixtureName(req)
+		if other, ok := seen[name]; ok {
+			t.Errorf("expected %s and %s to get different names, both got %s", url, other, name)
+		}
+		seen[name] = url
+	}
+}
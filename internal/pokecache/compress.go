@@ -0,0 +1,94 @@
+package pokecache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// WithCompression gzips values at level (one of the compress/gzip levels)
+// before storing them and decompresses them again on every read. Values
+// that don't get smaller, such as PNG sprites, are stored as they are.
+// Size limits apply to the compressed sizes. It panics if level isn't a
+// valid gzip level.
+func WithCompression(level int) Option {
+	if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+		panic(fmt.Sprintf("pokecache: invalid compression level %d", level))
+	}
+	return func(c *Cache) {
+		c.compress = true
+		c.level = level
+	}
+}
+
+// pack turns entry into what set stores, compressing its value when that
+// saves space. It runs before the shard is locked.
+func (c *Cache) pack(entry Entry) cacheEntry {
+	packed := cacheEntry{
+		val:          entry.Val,
+		rawSize:      len(entry.Val),
+		etag:         entry.ETag,
+		lastModified: entry.LastModified,
+	}
+	if !c.compress {
+		return packed
+	}
+	if val, err := c.deflate(entry.Val); err == nil && len(val) < len(entry.Val) {
+		packed.val = val
+		packed.compressed = true
+	}
+	return packed
+}
+
+func (c *Cache) deflate(val []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, ok := c.writers.Get().(*gzip.Writer)
+	if ok {
+		w.Reset(&buf)
+	} else {
+		var err error
+		w, err = gzip.NewWriterLevel(&buf, c.level)
+		if err != nil {
+			return nil, err
+		}
+	}
+	defer c.writers.Put(w)
+
+	if _, err := w.Write(val); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	// copy so the cache doesn't keep the buffer's spare capacity
+	return bytes.Clone(buf.Bytes()), nil
+}
+
+// value returns the entry's original bytes.
+func (e cacheEntry) value() ([]byte, error) {
+	if !e.compressed {
+		return e.val, nil
+	}
+	r, err := gzip.NewReader(bytes.NewReader(e.val))
+	if err != nil {
+		return nil, err
+	}
+	val := make([]byte, e.rawSize)
+	if _, err := io.ReadFull(r, val); err != nil {
+		return nil, fmt.Errorf("error decompressing value: %v", err)
+	}
+	return val, r.Close()
+}
+
+// sameValue reports whether e and other hold the same bytes, going by
+// their validators when they have them.
+func (e cacheEntry) sameValue(other cacheEntry) bool {
+	if e.etag != "" {
+		return e.etag == other.etag
+	}
+	if e.lastModified != "" {
+		return e.lastModified == other.lastModified
+	}
+	return e.compressed == other.compressed && sameBytes(e.val, other.val)
+}
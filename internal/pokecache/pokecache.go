@@ -1,57 +1,588 @@
 package pokecache
 
 import (
+	"container/list"
+	"context"
+	"regexp"
 	"sync"
 	"time"
 )
 
+// NeverExpire is a TTL for entries that stay fresh until they are evicted
+// or deleted.
+const NeverExpire time.Duration = -1
+
+// defaultStaleFor is how long entries with validators are kept past their
+// TTL unless WithStaleFor says otherwise.
+const defaultStaleFor = time.Hour
+
 type Cache struct {
+	clock      Clock
+	ttl        time.Duration
+	staleFor   time.Duration
+	policies   []Policy
+	maxEntries int
+	maxBytes   int
+	shardCount int
+	compress   bool
+	level      int
+	writers    sync.Pool
+	shards     []*shard
+	closeOnce  sync.Once
+	stop       chan struct{}
+	stopped    chan struct{}
+}
+
+// shard is an independently locked slice of the cache. Keys are spread
+// over shards by hash, and limits and LRU order apply per shard.
+type shard struct {
 	mu          sync.Mutex
+	maxEntries  int
+	maxBytes    int
+	bytes       int
+	rawBytes    int
+	gen         uint64
+	hits        int
+	misses      int
+	evictions   int
+	expirations int
+	recency     *list.List
 	cachedEntry map[string]cacheEntry
+	inflight    map[string]*call
 }
 
 type cacheEntry struct {
-	createdAt time.Time
-	val       []byte
+	createdAt    time.Time
+	val          []byte
+	compressed   bool
+	rawSize      int
+	etag         string
+	lastModified string
+	ttl          time.Duration
+	gen          uint64
+	decoded      any
+	elem         *list.Element
 }
 
-func NewCache(interval time.Duration) *Cache {
+// Entry is a cached value together with the HTTP validators needed to
+// revalidate it once it is stale.
+type Entry struct {
+	Val          []byte
+	ETag         string
+	LastModified string
+	Stale        bool
+}
+
+// call is a loader run shared by every caller that missed the same key
+// while it was in flight. waiters is guarded by the shard's mutex.
+type call struct {
+	done     chan struct{}
+	entry    Entry
+	err      error
+	panicked any
+	waiters  int
+	cancel   context.CancelFunc
+}
+
+// Stats counts cache activity. Evictions are entries dropped to stay within
+// the size limits and Expirations those the reaper dropped once expired.
+// Bytes is what the cache holds and RawBytes what it would hold without
+// compression.
+type Stats struct {
+	Hits        int
+	Misses      int
+	Evictions   int
+	Expirations int
+	Entries     int
+	Bytes       int
+	RawBytes    int
+}
+
+// CompressionRatio returns how many times smaller the stored values are
+// than the originals, or 1 for an empty cache.
+func (s Stats) CompressionRatio() float64 {
+	if s.Bytes == 0 {
+		return 1
+	}
+	return float64(s.RawBytes) / float64(s.Bytes)
+}
+
+// Policy sets the TTL for every key matching Pattern, so each kind of
+// resource can expire at its own pace.
+type Policy struct {
+	Pattern *regexp.Regexp
+	TTL     time.Duration
+}
+
+type Option func(*Cache)
+
+// WithTTL sets how long entries stay fresh when no policy matches them.
+// It defaults to the reap interval.
+func WithTTL(ttl time.Duration) Option {
+	return func(c *Cache) {
+		c.ttl = ttl
+	}
+}
+
+// WithStaleFor sets how long entries with an ETag or Last-Modified date
+// are kept after they expire, so they can be revalidated instead of
+// downloaded again. The reaper drops them once this window has passed.
+func WithStaleFor(d time.Duration) Option {
+	return func(c *Cache) {
+		c.staleFor = d
+	}
+}
+
+// WithPolicies sets per-key TTLs. The first policy whose pattern matches a
+// key wins.
+func WithPolicies(policies ...Policy) Option {
+	return func(c *Cache) {
+		c.policies = policies
+	}
+}
+
+// WithMaxEntries limits the cache to n entries, evicting the least
+// recently used ones first. Zero means no limit.
+func WithMaxEntries(n int) Option {
+	return func(c *Cache) {
+		c.maxEntries = n
+	}
+}
+
+// WithMaxBytes limits the total size of keys and values held by the
+// cache, evicting the least recently used entries first. Zero means no
+// limit.
+func WithMaxBytes(n int) Option {
+	return func(c *Cache) {
+		c.maxBytes = n
+	}
+}
+
+// WithClock replaces the wall clock used for expiry and reaping.
+func WithClock(clock Clock) Option {
+	return func(c *Cache) {
+		c.clock = clock
+	}
+}
+
+// WithShards splits the cache into n independently locked shards so
+// concurrent callers rarely contend. Size limits are divided evenly
+// between shards and LRU eviction happens within a shard. A cache never
+// has more shards than its smallest limit, so every shard gets some room.
+func WithShards(n int) Option {
+	return func(c *Cache) {
+		c.shardCount = n
+	}
+}
+
+// NewCache starts a cache that reaps expired entries every interval. Call
+// Close to stop its reaper goroutine.
+func NewCache(interval time.Duration, opts ...Option) *Cache {
 	newCache := &Cache{
-		cachedEntry: make(map[string]cacheEntry),
+		clock:      realClock{},
+		ttl:        interval,
+		staleFor:   defaultStaleFor,
+		shardCount: 1,
+		stop:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(newCache)
+	}
+
+	newCache.shardCount = max(1, newCache.shardCount)
+	for _, limit := range []int{newCache.maxEntries, newCache.maxBytes} {
+		if limit > 0 {
+			newCache.shardCount = min(newCache.shardCount, limit)
+		}
 	}
-	go newCache.reapLoop(interval)
+	for i := 0; i < newCache.shardCount; i++ {
+		newCache.shards = append(newCache.shards, &shard{
+			maxEntries:  perShard(newCache.maxEntries, newCache.shardCount, i),
+			maxBytes:    perShard(newCache.maxBytes, newCache.shardCount, i),
+			recency:     list.New(),
+			cachedEntry: make(map[string]cacheEntry),
+			inflight:    make(map[string]*call),
+		})
+	}
+
+	go newCache.reapLoop(newCache.clock.NewTicker(interval))
 	return newCache
 }
 
+// perShard returns shard i's part of limit. The first limit%shards shards
+// get one more than the rest so the parts add up to limit exactly.
+func perShard(limit, shards, i int) int {
+	part := limit / shards
+	if i < limit%shards {
+		part++
+	}
+	return part
+}
+
+func (c *Cache) shardFor(key string) *shard {
+	if len(c.shards) == 1 {
+		return c.shards[0]
+	}
+	// inline FNV-1a to avoid allocating on every lookup
+	hash := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint32(key[i])
+		hash *= 16777619
+	}
+	return c.shards[hash%uint32(len(c.shards))]
+}
+
+// Close stops the reaper and waits for it to exit. It is safe to call
+// more than once; the cache stays usable but entries are no longer reaped.
+func (c *Cache) Close() {
+	c.closeOnce.Do(func() {
+		close(c.stop)
+	})
+	<-c.stopped
+}
+
 func (c *Cache) Add(key string, val []byte) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	c.cachedEntry[key] = cacheEntry{
-		createdAt: time.Now(),
-		val:       val,
+	c.AddEntry(key, Entry{Val: val})
+}
+
+// AddWithTTL stores val with its own TTL, overriding any policy. Use
+// NeverExpire to keep it fresh until evicted.
+func (c *Cache) AddWithTTL(key string, val []byte, ttl time.Duration) {
+	packed := c.pack(Entry{Val: val})
+	s := c.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c.set(s, key, packed, ttl)
+}
+
+func (c *Cache) AddEntry(key string, entry Entry) {
+	packed := c.pack(entry)
+	s := c.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c.set(s, key, packed, c.ttlFor(key))
+}
+
+func (c *Cache) ttlFor(key string) time.Duration {
+	for _, policy := range c.policies {
+		if policy.Pattern.MatchString(key) {
+			return policy.TTL
+		}
 	}
+	return c.ttl
 }
 
+// Get returns the value for key if it is present and not stale.
 func (c *Cache) Get(key string) ([]byte, bool) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	entry, ok := c.cachedEntry[key]
+	s := c.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cached, ok := s.cachedEntry[key]
 	if !ok {
+		s.misses++
+		return nil, false
+	}
+	s.recency.MoveToFront(cached.elem)
+	entry, err := c.unpack(s, key, cached)
+	if err != nil {
+		s.misses++
 		return nil, false
 	}
-	return entry.val, true
+	if entry.Stale {
+		s.misses++
+		return nil, false
+	}
+	s.hits++
+	return entry.Val, true
 }
 
-func (c *Cache) reapLoop(interval time.Duration) {
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
-	for range ticker.C {
-		c.mu.Lock()
-		for key, val := range c.cachedEntry {
-			if time.Since(val.createdAt) > interval {
-				delete(c.cachedEntry, key)
+// Lookup returns the entry for key, including stale entries that were kept
+// for revalidation.
+func (c *Cache) Lookup(key string) (Entry, bool) {
+	s := c.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.cachedEntry[key]
+	if !ok {
+		return Entry{}, false
+	}
+	s.recency.MoveToFront(entry.elem)
+	exported, err := c.unpack(s, key, entry)
+	if err != nil {
+		return Entry{}, false
+	}
+	return exported, true
+}
+
+// unpack returns cached, which s holds under key, as callers see it.
+// Compressed values are decompressed with s.mu released so a large value
+// doesn't hold up the rest of the shard. s.mu must be held and is held
+// again on return. If the value can't be read, the entry is dropped
+// unless it was replaced in the meantime.
+func (c *Cache) unpack(s *shard, key string, cached cacheEntry) (Entry, error) {
+	if !cached.compressed {
+		return c.export(cached)
+	}
+	s.mu.Unlock()
+	entry, err := c.export(cached)
+	s.mu.Lock()
+	if err != nil {
+		if current, ok := s.cachedEntry[key]; ok && current.gen == cached.gen {
+			s.remove(key)
+		}
+	}
+	return entry, err
+}
+
+// export returns entry as callers see it, with its value decompressed.
+// An error means the stored value is unreadable and should be dropped.
+func (c *Cache) export(entry cacheEntry) (Entry, error) {
+	val, err := entry.value()
+	if err != nil {
+		return Entry{}, err
+	}
+	return Entry{
+		Val:          val,
+		ETag:         entry.etag,
+		LastModified: entry.lastModified,
+		Stale:        c.expired(entry, c.clock.Now()),
+	}, nil
+}
+
+func (c *Cache) expired(entry cacheEntry, now time.Time) bool {
+	return entry.ttl != NeverExpire && now.Sub(entry.createdAt) > entry.ttl
+}
+
+// GetOrFetch returns the cached value for key, or calls loader to fetch
+// it. Concurrent misses for the same key share a single loader call and
+// its result; errors are returned to every waiter but not cached.
+func (c *Cache) GetOrFetch(ctx context.Context, key string, loader func(context.Context) ([]byte, error)) ([]byte, error) {
+	entry, err := c.GetOrFetchEntry(ctx, key, func(ctx context.Context, _ Entry) (Entry, error) {
+		val, err := loader(ctx)
+		return Entry{Val: val}, err
+	})
+	return entry.Val, err
+}
+
+// GetOrFetchEntry works like GetOrFetch but passes any stale entry to
+// loader, so it can revalidate it and return it again with a fresh
+// timestamp instead of downloading the value.
+//
+// A caller whose ctx is done stops waiting without affecting the others.
+// The loader's context is only cancelled once every caller has stopped
+// waiting. If the loader panics, the callers waiting for it panic too.
+func (c *Cache) GetOrFetchEntry(ctx context.Context, key string, loader func(ctx context.Context, stale Entry) (Entry, error)) (Entry, error) {
+	s := c.shardFor(key)
+	s.mu.Lock()
+	stale := Entry{}
+	if cached, ok := s.cachedEntry[key]; ok {
+		s.recency.MoveToFront(cached.elem)
+		exported, err := c.unpack(s, key, cached)
+		switch {
+		case err != nil:
+			// unreadable, so load it as if it were missing
+		case !exported.Stale:
+			s.hits++
+			s.mu.Unlock()
+			return exported, nil
+		default:
+			stale = exported
+		}
+	}
+	s.misses++
+	pending, ok := s.inflight[key]
+	if !ok {
+		loadCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		pending = &call{done: make(chan struct{}), cancel: cancel}
+		s.inflight[key] = pending
+		go c.load(loadCtx, s, key, pending, loader, stale)
+	}
+	pending.waiters++
+	s.mu.Unlock()
+
+	select {
+	case <-pending.done:
+		if pending.panicked != nil {
+			panic(pending.panicked)
+		}
+		return pending.entry, pending.err
+	case <-ctx.Done():
+		s.mu.Lock()
+		pending.waiters--
+		if pending.waiters == 0 {
+			// nobody wants the result any more, so later callers start
+			// a fresh load instead of joining a cancelled one
+			pending.cancel()
+			if s.inflight[key] == pending {
+				delete(s.inflight, key)
 			}
 		}
-		c.mu.Unlock()
+		s.mu.Unlock()
+		return Entry{}, ctx.Err()
+	}
+}
+
+// load runs loader for pending and caches its result. The cleanup is
+// deferred so a panicking loader still releases its waiters.
+func (c *Cache) load(ctx context.Context, s *shard, key string, pending *call, loader func(context.Context, Entry) (Entry, error), stale Entry) {
+	defer func() {
+		pending.panicked = recover()
+		s.mu.Lock()
+		if s.inflight[key] == pending {
+			delete(s.inflight, key)
+		}
+		s.mu.Unlock()
+		pending.cancel()
+		close(pending.done)
+	}()
+
+	entry, err := loader(ctx, stale)
+	if err == nil {
+		packed := c.pack(entry)
+		s.mu.Lock()
+		c.set(s, key, packed, c.ttlFor(key))
+		s.mu.Unlock()
+	}
+	pending.entry, pending.err = entry, err
+}
+
+// set stores an entry made by pack as the most recently used one in s and
+// evicts entries until s is back within its limits. An entry larger than
+// the shard's byte limit is not stored, since it would evict everything
+// and then itself. A decoded value is kept if the new entry holds the
+// same bytes or the same validators, as it does after a 304 Not Modified.
+// s.mu must be held.
+func (c *Cache) set(s *shard, key string, entry cacheEntry, ttl time.Duration) {
+	if old, ok := s.cachedEntry[key]; ok && old.sameValue(entry) {
+		entry.decoded = old.decoded
+	}
+	s.remove(key)
+	if s.maxBytes > 0 && len(key)+len(entry.val) > s.maxBytes {
+		return
+	}
+	s.gen++
+	entry.createdAt = c.clock.Now()
+	entry.ttl = ttl
+	entry.gen = s.gen
+	entry.elem = s.recency.PushFront(key)
+	s.cachedEntry[key] = entry
+	s.bytes += len(key) + len(entry.val)
+	s.rawBytes += len(key) + entry.rawSize
+
+	for s.overLimit() {
+		oldest := s.recency.Back()
+		s.remove(oldest.Value.(string))
+		s.evictions++
+	}
+}
+
+func (s *shard) overLimit() bool {
+	if s.maxEntries > 0 && len(s.cachedEntry) > s.maxEntries {
+		return true
+	}
+	return s.maxBytes > 0 && s.bytes > s.maxBytes
+}
+
+// remove deletes key if present. s.mu must be held.
+func (s *shard) remove(key string) {
+	entry, ok := s.cachedEntry[key]
+	if !ok {
+		return
+	}
+	s.recency.Remove(entry.elem)
+	s.bytes -= len(key) + len(entry.val)
+	s.rawBytes -= len(key) + entry.rawSize
+	delete(s.cachedEntry, key)
+}
+
+func (c *Cache) Stats() Stats {
+	stats := Stats{}
+	for _, s := range c.shards {
+		s.mu.Lock()
+		stats.Hits += s.hits
+		stats.Misses += s.misses
+		stats.Evictions += s.evictions
+		stats.Expirations += s.expirations
+		stats.Entries += len(s.cachedEntry)
+		stats.Bytes += s.bytes
+		stats.RawBytes += s.rawBytes
+		s.mu.Unlock()
+	}
+	return stats
+}
+
+// Keys returns every cached key, most recently used first within each
+// shard.
+func (c *Cache) Keys() []string {
+	keys := []string{}
+	for _, s := range c.shards {
+		s.mu.Lock()
+		for elem := s.recency.Front(); elem != nil; elem = elem.Next() {
+			keys = append(keys, elem.Value.(string))
+		}
+		s.mu.Unlock()
+	}
+	return keys
+}
+
+// Delete removes key and reports whether it was cached.
+func (c *Cache) Delete(key string) bool {
+	s := c.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.cachedEntry[key]
+	s.remove(key)
+	return ok
+}
+
+// Clear removes every entry and returns how many there were. Statistics
+// are kept.
+func (c *Cache) Clear() int {
+	n := 0
+	for _, s := range c.shards {
+		s.mu.Lock()
+		n += len(s.cachedEntry)
+		s.cachedEntry = make(map[string]cacheEntry)
+		s.recency.Init()
+		s.bytes = 0
+		s.rawBytes = 0
+		s.mu.Unlock()
+	}
+	return n
+}
+
+// reapLoop drops expired entries one shard at a time, so only one shard
+// is locked while it is scanned. Entries with validators are kept for the
+// stale window so they can be revalidated instead of downloaded again.
+func (c *Cache) reapLoop(ticker Ticker) {
+	defer close(c.stopped)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C():
+		}
+
+		for _, s := range c.shards {
+			c.reap(s)
+		}
+	}
+}
+
+func (c *Cache) reap(s *shard) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := c.clock.Now()
+	for key, val := range s.cachedEntry {
+		cutoff := now
+		if val.etag != "" || val.lastModified != "" {
+			cutoff = now.Add(-c.staleFor)
+		}
+		if c.expired(val, cutoff) {
+			s.remove(key)
+			s.expirations++
+		}
 	}
 }
@@ -1,7 +1,16 @@
 package pokecache
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -25,6 +34,7 @@ func TestAddGet(t *testing.T) {
 	for i, c := range cases {
 		t.Run(fmt.Sprintf("Test case %v", i), func(t *testing.T) {
 			cache := NewCache(interval)
+			defer cache.Close()
 			cache.Add(c.key, c.val)
 			val, ok := cache.Get(c.key)
 			if !ok {
@@ -39,10 +49,70 @@ func TestAddGet(t *testing.T) {
 	}
 }
 
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+type fakeTicker struct {
+	c       chan time.Time
+	period  time.Duration
+	next    time.Time
+	stopped bool
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) NewTicker(d time.Duration) Ticker {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ticker := &fakeTicker{c: make(chan time.Time), period: d, next: f.now.Add(d)}
+	f.tickers = append(f.tickers, ticker)
+	return ticker
+}
+
+// Advance moves the clock forward and delivers every tick that became
+// due, blocking until each one has been received.
+func (f *fakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	f.now = f.now.Add(d)
+	var due []*fakeTicker
+	for _, ticker := range f.tickers {
+		for !ticker.stopped && !ticker.next.After(f.now) {
+			due = append(due, ticker)
+			ticker.next = ticker.next.Add(ticker.period)
+		}
+	}
+	now := f.now
+	f.mu.Unlock()
+
+	for _, ticker := range due {
+		ticker.c <- now
+	}
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTicker) Stop() {
+	t.stopped = true
+}
+
 func TestReapLoop(t *testing.T) {
 	const baseTime = 5 * time.Millisecond
 	const waitTime = baseTime + 5*time.Millisecond
-	cache := NewCache(baseTime)
+	clock := newFakeClock()
+	cache := NewCache(baseTime, WithClock(clock))
 	cache.Add("https://pokeapi.co/api/v2/location-area/", []byte("bulbasaur"))
 
 	_, ok := cache.Get("https://pokeapi.co/api/v2/location-area/")
@@ -51,11 +121,790 @@ func TestReapLoop(t *testing.T) {
 		return
 	}
 
-	time.Sleep(waitTime)
+	clock.Advance(waitTime)
 
 	_, ok = cache.Get("https://pokeapi.co/api/v2/location-area/")
 	if ok {
 		t.Errorf("expected to not find key")
 		return
 	}
+
+	cache.Close()
+	if stats := cache.Stats(); stats.Entries != 0 || stats.Expirations != 1 || stats.Evictions != 0 {
+		t.Errorf("expected the reaper to expire the entry, got %+v", stats)
+	}
+}
+
+func TestReapStale(t *testing.T) {
+	const key = "https://pokeapi.co/api/v2/pokemon/eevee"
+	clock := newFakeClock()
+	cache := NewCache(time.Minute, WithClock(clock), WithStaleFor(time.Hour))
+	defer cache.Close()
+	cache.AddEntry(key, Entry{Val: []byte("eevee"), ETag: `"v1"`})
+
+	// every Advance delivers a tick, and the reaper only takes the next
+	// one once it has finished the last reap
+	for i := 0; i < 3; i++ {
+		clock.Advance(time.Minute)
+	}
+	if entry, ok := cache.Lookup(key); !ok || !entry.Stale {
+		t.Fatalf("expected a stale entry kept for revalidation, got %+v, %v", entry, ok)
+	}
+
+	clock.Advance(time.Hour)
+	clock.Advance(time.Minute)
+	if _, ok := cache.Lookup(key); ok {
+		t.Errorf("expected the entry to be reaped after the stale window")
+	}
+}
+
+func TestClose(t *testing.T) {
+	cache := NewCache(time.Millisecond)
+	cache.Add("https://pokeapi.co/api/v2/location-area/", []byte("bulbasaur"))
+
+	done := make(chan struct{})
+	go func() {
+		cache.Close()
+		cache.Close()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("expected Close to return")
+	}
+
+	if _, ok := cache.Get("https://pokeapi.co/api/v2/location-area/"); !ok {
+		t.Errorf("expected cache to stay usable after Close")
+	}
+}
+
+func TestGetOrFetch(t *testing.T) {
+	const key = "https://pokeapi.co/api/v2/pokemon/pikachu"
+	cache := NewCache(5 * time.Second)
+	defer cache.Close()
+
+	var calls int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	loader := func(context.Context) ([]byte, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			close(started)
+		}
+		<-release
+		return []byte("pikachu"), nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]string, 10)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			val, err := cache.GetOrFetch(context.Background(), key, loader)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			results[i] = string(val)
+		}()
+	}
+
+	<-started
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("expected 1 loader call, got %d", calls)
+	}
+	for i, val := range results {
+		if val != "pikachu" {
+			t.Errorf("result %d: expected pikachu, got %q", i, val)
+		}
+	}
+	if _, ok := cache.Get(key); !ok {
+		t.Errorf("expected to find key")
+	}
+}
+
+func TestGetOrFetchError(t *testing.T) {
+	const key = "https://pokeapi.co/api/v2/pokemon/missingno"
+	cache := NewCache(5 * time.Second)
+	defer cache.Close()
+	errMissing := errors.New("missing")
+
+	calls := 0
+	loader := func(context.Context) ([]byte, error) {
+		calls++
+		return nil, errMissing
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := cache.GetOrFetch(context.Background(), key, loader); !errors.Is(err, errMissing) {
+			t.Errorf("expected loader error, got %v", err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("expected errors not to be cached, got %d loader calls", calls)
+	}
+	if _, ok := cache.Get(key); ok {
+		t.Errorf("expected to not find key")
+	}
+}
+
+func TestGetOrFetchCancel(t *testing.T) {
+	const key = "https://pokeapi.co/api/v2/pokemon/snorlax"
+	cache := NewCache(5 * time.Second)
+	defer cache.Close()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	loaderCtx := make(chan context.Context, 1)
+	loader := func(ctx context.Context) ([]byte, error) {
+		loaderCtx <- ctx
+		close(started)
+		select {
+		case <-release:
+			return []byte("snorlax"), nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	// the first caller gives up, the second still gets the value
+	first, cancelFirst := context.WithCancel(context.Background())
+	firstErr := make(chan error)
+	go func() {
+		_, err := cache.GetOrFetch(first, key, loader)
+		firstErr <- err
+	}()
+	<-started
+	second := make(chan string)
+	go func() {
+		val, err := cache.GetOrFetch(context.Background(), key, loader)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		second <- string(val)
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	cancelFirst()
+	if err := <-firstErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the first caller to be cancelled, got %v", err)
+	}
+	if err := (<-loaderCtx).Err(); err != nil {
+		t.Errorf("expected the loader to keep running, got %v", err)
+	}
+	close(release)
+	if val := <-second; val != "snorlax" {
+		t.Errorf("expected snorlax, got %q", val)
+	}
+
+	// once every caller gives up the loader is cancelled
+	cache.Delete(key)
+	started = make(chan struct{})
+	release = make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	if _, err := cache.GetOrFetch(ctx, key, loader); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the caller to be cancelled, got %v", err)
+	}
+	select {
+	case <-(<-loaderCtx).Done():
+	case <-time.After(time.Second):
+		t.Errorf("expected the loader to be cancelled")
+	}
+}
+
+func TestGetOrFetchPanic(t *testing.T) {
+	const key = "https://pokeapi.co/api/v2/pokemon/missingno"
+	cache := NewCache(5 * time.Second)
+	defer cache.Close()
+
+	func() {
+		defer func() {
+			if r := recover(); r != "bad loader" {
+				t.Errorf("expected the loader's panic, got %v", r)
+			}
+		}()
+		cache.GetOrFetch(context.Background(), key, func(context.Context) ([]byte, error) {
+			panic("bad loader")
+		})
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		val, err := cache.GetOrFetch(context.Background(), key, func(context.Context) ([]byte, error) {
+			return []byte("missingno"), nil
+		})
+		if err != nil || string(val) != "missingno" {
+			t.Errorf("expected missingno, got %q, %v", val, err)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("expected a later call not to wait for the panicked one")
+	}
+}
+
+func TestStaleEntryKept(t *testing.T) {
+	const baseTime = 5 * time.Millisecond
+	const waitTime = baseTime + 5*time.Millisecond
+	const key = "https://pokeapi.co/api/v2/pokemon/eevee"
+	clock := newFakeClock()
+	cache := NewCache(baseTime, WithClock(clock))
+	defer cache.Close()
+	cache.AddEntry(key, Entry{Val: []byte("eevee"), ETag: `"v1"`})
+
+	clock.Advance(waitTime)
+
+	if _, ok := cache.Get(key); ok {
+		t.Errorf("expected stale entry to be hidden from Get")
+	}
+	entry, ok := cache.Lookup(key)
+	if !ok || !entry.Stale || entry.ETag != `"v1"` {
+		t.Fatalf("expected stale entry with validators, got %+v", entry)
+	}
+
+	refreshed, err := cache.GetOrFetchEntry(context.Background(), key, func(_ context.Context, stale Entry) (Entry, error) {
+		if string(stale.Val) != "eevee" {
+			t.Errorf("expected loader to receive stale entry, got %+v", stale)
+		}
+		return stale, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(refreshed.Val) != "eevee" {
+		t.Errorf("expected refreshed value eevee, got %s", refreshed.Val)
+	}
+	if _, ok := cache.Get(key); !ok {
+		t.Errorf("expected entry to be fresh after revalidation")
+	}
+}
+
+func TestEvictionOrder(t *testing.T) {
+	// ops are "+key" to add a key and "?key" to get it
+	cases := []struct {
+		opts    []Option
+		ops     []string
+		present []string
+		evicted []string
+	}{
+		{
+			opts:    []Option{WithMaxEntries(2)},
+			ops:     []string{"+a", "+b", "+c"},
+			present: []string{"b", "c"},
+			evicted: []string{"a"},
+		},
+		{
+			opts:    []Option{WithMaxEntries(2)},
+			ops:     []string{"+a", "+b", "?a", "+c"},
+			present: []string{"a", "c"},
+			evicted: []string{"b"},
+		},
+		{
+			opts:    []Option{WithMaxEntries(3)},
+			ops:     []string{"+a", "+b", "+c", "+a", "+d"},
+			present: []string{"a", "c", "d"},
+			evicted: []string{"b"},
+		},
+		{
+			// each entry is 1 byte of key plus 4 bytes of value
+			opts:    []Option{WithMaxBytes(12)},
+			ops:     []string{"+a", "+b", "+c"},
+			present: []string{"b", "c"},
+			evicted: []string{"a"},
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Test case %v", i), func(t *testing.T) {
+			cache := NewCache(5*time.Second, c.opts...)
+			defer cache.Close()
+			for _, op := range c.ops {
+				if op[0] == '+' {
+					cache.Add(op[1:], []byte("mew!"))
+				} else {
+					cache.Get(op[1:])
+				}
+			}
+			for _, key := range c.evicted {
+				if _, ok := cache.Get(key); ok {
+					t.Errorf("expected key %s to be evicted", key)
+				}
+			}
+			for _, key := range c.present {
+				if _, ok := cache.Get(key); !ok {
+					t.Errorf("expected to find key %s", key)
+				}
+			}
+		})
+	}
+}
+
+func TestOversizedEntry(t *testing.T) {
+	cache := NewCache(5*time.Second, WithMaxBytes(16))
+	defer cache.Close()
+	cache.Add("a", []byte("mew!"))
+	cache.Add("big", []byte("far too large for the cache"))
+
+	if _, ok := cache.Get("big"); ok {
+		t.Errorf("expected the oversized entry not to be stored")
+	}
+	if _, ok := cache.Get("a"); !ok {
+		t.Errorf("expected the oversized entry not to evict a")
+	}
+	if stats := cache.Stats(); stats.Evictions != 0 || stats.Bytes != 5 {
+		t.Errorf("expected nothing evicted, got %+v", stats)
+	}
+}
+
+func TestStats(t *testing.T) {
+	cache := NewCache(5*time.Second, WithMaxEntries(2))
+	defer cache.Close()
+	cache.Add("a", []byte("mew!"))
+	cache.Add("b", []byte("mew!"))
+	cache.Get("a")
+	cache.Get("missing")
+	cache.Add("c", []byte("mew!"))
+	cache.GetOrFetch(context.Background(), "c", func(context.Context) ([]byte, error) { return nil, nil })
+
+	want := Stats{Hits: 2, Misses: 1, Evictions: 1, Entries: 2, Bytes: 10, RawBytes: 10}
+	if got := cache.Stats(); got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+
+	keys := cache.Keys()
+	if len(keys) != 2 || keys[0] != "c" || keys[1] != "a" {
+		t.Errorf("expected keys [c a], got %v", keys)
+	}
+
+	if !cache.Delete("a") {
+		t.Errorf("expected a to be deleted")
+	}
+	if cache.Delete("a") {
+		t.Errorf("expected a to be gone")
+	}
+	if n := cache.Clear(); n != 1 {
+		t.Errorf("expected to clear 1 entry, got %d", n)
+	}
+	if stats := cache.Stats(); stats.Entries != 0 || stats.Bytes != 0 {
+		t.Errorf("expected empty cache, got %+v", stats)
+	}
+}
+
+func TestTTLs(t *testing.T) {
+	clock := newFakeClock()
+	cache := NewCache(time.Minute,
+		WithClock(clock),
+		WithTTL(10*time.Minute),
+		WithPolicies(
+			Policy{Pattern: regexp.MustCompile(`/location-area/(\?.*)?$`), TTL: 5 * time.Minute},
+			Policy{Pattern: regexp.MustCompile(`/type/`), TTL: NeverExpire},
+		),
+	)
+	defer cache.Close()
+
+	cache.Add("https://pokeapi.co/api/v2/location-area/?offset=20", []byte("page"))
+	cache.Add("https://pokeapi.co/api/v2/location-area/canalave-city-area", []byte("area"))
+	cache.Add("https://pokeapi.co/api/v2/type/fire", []byte("fire"))
+	cache.AddWithTTL("https://pokeapi.co/api/v2/pokemon/ditto", []byte("ditto"), time.Minute)
+	cache.AddWithTTL("https://pokeapi.co/api/v2/location-area/", []byte("page"), NeverExpire)
+
+	cases := []struct {
+		advance time.Duration
+		fresh   []string
+		expired []string
+	}{
+		{
+			advance: 2 * time.Minute,
+			fresh: []string{
+				"https://pokeapi.co/api/v2/location-area/?offset=20",
+				"https://pokeapi.co/api/v2/location-area/canalave-city-area",
+				"https://pokeapi.co/api/v2/type/fire",
+				"https://pokeapi.co/api/v2/location-area/",
+			},
+			expired: []string{"https://pokeapi.co/api/v2/pokemon/ditto"},
+		},
+		{
+			advance: 5 * time.Minute,
+			fresh: []string{
+				"https://pokeapi.co/api/v2/location-area/canalave-city-area",
+				"https://pokeapi.co/api/v2/type/fire",
+				"https://pokeapi.co/api/v2/location-area/",
+			},
+			expired: []string{"https://pokeapi.co/api/v2/location-area/?offset=20"},
+		},
+		{
+			advance: 24 * time.Hour,
+			fresh: []string{
+				"https://pokeapi.co/api/v2/type/fire",
+				"https://pokeapi.co/api/v2/location-area/",
+			},
+			expired: []string{"https://pokeapi.co/api/v2/location-area/canalave-city-area"},
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Test case %v", i), func(t *testing.T) {
+			clock.Advance(c.advance)
+			for _, key := range c.fresh {
+				if _, ok := cache.Get(key); !ok {
+					t.Errorf("expected %s to be fresh", key)
+				}
+			}
+			for _, key := range c.expired {
+				if _, ok := cache.Get(key); ok {
+					t.Errorf("expected %s to be expired", key)
+				}
+			}
+		})
+	}
+}
+
+type testPokemon struct {
+	Name  string `json:"name"`
+	Moves []struct {
+		Move struct {
+			Name string `json:"name"`
+			URL  string `json:"url"`
+		} `json:"move"`
+	} `json:"moves"`
+}
+
+func testPokemonJSON(moves int) []byte {
+	var sb strings.Builder
+	sb.WriteString(`{"name":"mew","moves":[`)
+	for i := 0; i < moves; i++ {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		fmt.Fprintf(&sb, `{"move":{"name":"move-%d","url":"https://pokeapi.co/api/v2/move/%d/"}}`, i, i)
+	}
+	sb.WriteString("]}")
+	return []byte(sb.String())
+}
+
+func decodeTestPokemon(data []byte) (testPokemon, error) {
+	var p testPokemon
+	err := json.Unmarshal(data, &p)
+	return p, err
+}
+
+func TestTyped(t *testing.T) {
+	const key = "https://pokeapi.co/api/v2/pokemon/mew"
+	cache := NewCache(5 * time.Second)
+	defer cache.Close()
+
+	decodes := 0
+	typed := NewTyped(cache, func(data []byte) (testPokemon, error) {
+		decodes++
+		return decodeTestPokemon(data)
+	})
+
+	fetches := 0
+	fetch := func() ([]byte, error) {
+		fetches++
+		data := testPokemonJSON(3)
+		cache.Add(key, data)
+		return data, nil
+	}
+
+	for i := 0; i < 3; i++ {
+		p, err := typed.Load(key, fetch)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if p.Name != "mew" || len(p.Moves) != 3 {
+			t.Errorf("unexpected value: %+v", p)
+		}
+	}
+	if fetches != 1 || decodes != 1 {
+		t.Errorf("expected 1 fetch and 1 decode, got %d and %d", fetches, decodes)
+	}
+
+	cache.Add(key, testPokemonJSON(5))
+	p, ok := typed.Get(key)
+	if !ok || len(p.Moves) != 5 {
+		t.Errorf("expected replaced bytes to be decoded again, got %+v", p)
+	}
+	if decodes != 2 {
+		t.Errorf("expected 2 decodes, got %d", decodes)
+	}
+
+	cache.Delete(key)
+	if _, ok := typed.Get(key); ok {
+		t.Errorf("expected decoded value to go away with its bytes")
+	}
+}
+
+func TestCompression(t *testing.T) {
+	const key = "https://pokeapi.co/api/v2/pokemon/mew"
+	cache := NewCache(5*time.Second, WithCompression(gzip.BestSpeed))
+	defer cache.Close()
+
+	data := testPokemonJSON(100)
+	cache.Add(key, data)
+	val, ok := cache.Get(key)
+	if !ok || !bytes.Equal(val, data) {
+		t.Fatalf("expected to get the original bytes back")
+	}
+	stats := cache.Stats()
+	if stats.RawBytes != len(key)+len(data) || stats.Bytes >= stats.RawBytes {
+		t.Errorf("expected value to be stored compressed, got %+v", stats)
+	}
+	if ratio := stats.CompressionRatio(); ratio < 2 {
+		t.Errorf("expected a compression ratio above 2, got %.2f", ratio)
+	}
+
+	// too short to shrink, so it is stored as is
+	cache.Add("short", []byte("mew"))
+	if got := cache.Stats(); got.Bytes-stats.Bytes != len("short")+3 {
+		t.Errorf("expected short value to be stored uncompressed, got %+v", got)
+	}
+	if val, ok := cache.Get("short"); !ok || string(val) != "mew" {
+		t.Errorf("expected mew, got %s", val)
+	}
+
+	cache.AddEntry(key, Entry{Val: data, ETag: `"v1"`})
+	decodes := 0
+	typed := NewTyped(cache, func(data []byte) (testPokemon, error) {
+		decodes++
+		return decodeTestPokemon(data)
+	})
+	for i := 0; i < 3; i++ {
+		if p, ok := typed.Get(key); !ok || len(p.Moves) != 100 {
+			t.Fatalf("unexpected value: %+v", p)
+		}
+	}
+
+	// a revalidated entry comes back with the same validator
+	entry, _ := cache.Lookup(key)
+	cache.AddEntry(key, entry)
+	if _, ok := typed.Get(key); !ok || decodes != 1 {
+		t.Errorf("expected 1 decode, got %d", decodes)
+	}
+}
+
+func TestCompressionUnreadable(t *testing.T) {
+	const key = "https://pokeapi.co/api/v2/pokemon/mew"
+	cache := NewCache(5*time.Second, WithCompression(gzip.BestSpeed))
+	defer cache.Close()
+
+	cache.Add(key, testPokemonJSON(100))
+	s := cache.shardFor(key)
+	s.mu.Lock()
+	entry := s.cachedEntry[key]
+	entry.val = bytes.Clone(entry.val)
+	entry.val[0] ^= 0xff
+	s.cachedEntry[key] = entry
+	s.mu.Unlock()
+
+	if _, ok := cache.Get(key); ok {
+		t.Errorf("expected an unreadable value to be a miss")
+	}
+	if stats := cache.Stats(); stats.Entries != 0 || stats.Misses != 1 || stats.Hits != 0 {
+		t.Errorf("expected the entry to be dropped, got %+v", stats)
+	}
+}
+
+func TestCompressionLevel(t *testing.T) {
+	for _, level := range []int{gzip.HuffmanOnly - 1, gzip.BestCompression + 1} {
+		t.Run(fmt.Sprint(level), func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected level %d to panic", level)
+				}
+			}()
+			WithCompression(level)
+		})
+	}
+}
+
+func TestTypedLoadCompressed(t *testing.T) {
+	const key = "https://pokeapi.co/api/v2/pokemon/mew"
+	cache := NewCache(5*time.Second, WithCompression(gzip.DefaultCompression))
+	defer cache.Close()
+
+	decodes := 0
+	typed := NewTyped(cache, func(data []byte) (testPokemon, error) {
+		decodes++
+		return decodeTestPokemon(data)
+	})
+	fetch := func() ([]byte, error) {
+		data := testPokemonJSON(50)
+		cache.Add(key, data)
+		return data, nil
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := typed.Load(key, fetch); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if decodes != 1 {
+		t.Errorf("expected 1 decode, got %d", decodes)
+	}
+}
+
+func TestTypedLoadMisses(t *testing.T) {
+	const key = "https://pokeapi.co/api/v2/pokemon/mew"
+	cache := NewCache(5 * time.Second)
+	defer cache.Close()
+
+	typed := NewTyped(cache, decodeTestPokemon)
+	fetch := func() ([]byte, error) {
+		return cache.GetOrFetch(context.Background(), key, func(context.Context) ([]byte, error) {
+			return testPokemonJSON(3), nil
+		})
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := typed.Load(key, fetch); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	stats := cache.Stats()
+	if stats.Misses != 1 || stats.Hits != 2 {
+		t.Errorf("expected 1 miss and 2 hits, got %d and %d", stats.Misses, stats.Hits)
+	}
+}
+
+func BenchmarkGetUnmarshal(b *testing.B) {
+	const key = "https://pokeapi.co/api/v2/pokemon/mew"
+	cache := NewCache(time.Minute)
+	defer cache.Close()
+	cache.Add(key, testPokemonJSON(300))
+
+	for b.Loop() {
+		data, _ := cache.Get(key)
+		if _, err := decodeTestPokemon(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTypedGet(b *testing.B) {
+	const key = "https://pokeapi.co/api/v2/pokemon/mew"
+	cache := NewCache(time.Minute)
+	defer cache.Close()
+	cache.Add(key, testPokemonJSON(300))
+	typed := NewTyped(cache, decodeTestPokemon)
+
+	for b.Loop() {
+		if _, ok := typed.Get(key); !ok {
+			b.Fatal("expected to find key")
+		}
+	}
+}
+
+func TestShards(t *testing.T) {
+	cache := NewCache(5*time.Second, WithShards(8), WithMaxEntries(800))
+	defer cache.Close()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				key := fmt.Sprintf("https://pokeapi.co/api/v2/pokemon/%d", g*50+i)
+				cache.Add(key, []byte(key))
+				if val, ok := cache.Get(key); !ok || string(val) != key {
+					t.Errorf("expected to find key %s", key)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats := cache.Stats()
+	if stats.Entries != 400 || stats.Hits != 400 || stats.Evictions != 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if keys := cache.Keys(); len(keys) != 400 {
+		t.Errorf("expected 400 keys, got %d", len(keys))
+	}
+	if !cache.Delete("https://pokeapi.co/api/v2/pokemon/7") {
+		t.Errorf("expected key to be deleted")
+	}
+	if n := cache.Clear(); n != 399 {
+		t.Errorf("expected to clear 399 entries, got %d", n)
+	}
+}
+
+func benchmarkParallel(b *testing.B, shards int) {
+	cache := NewCache(time.Minute, WithShards(shards))
+	defer cache.Close()
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("https://pokeapi.co/api/v2/pokemon/%d", i)
+		cache.Add(keys[i], []byte("pokemon"))
+	}
+
+	var workers int32
+	b.RunParallel(func(pb *testing.PB) {
+		// start each worker on a different key so they don't move in lockstep
+		i := int(atomic.AddInt32(&workers, 1)) * 97
+		for pb.Next() {
+			key := keys[i%len(keys)]
+			if i%10 == 0 {
+				cache.Add(key, []byte("pokemon"))
+			} else {
+				cache.Get(key)
+			}
+			i++
+		}
+	})
+}
+
+func TestShardLimits(t *testing.T) {
+	cases := []struct {
+		shards     int
+		maxEntries int
+		maxBytes   int
+		wantShards int
+	}{
+		{shards: 8, maxEntries: 100, maxBytes: 1 << 20, wantShards: 8},
+		{shards: 16, maxEntries: 10, wantShards: 10},
+		{shards: 4, maxBytes: 3, wantShards: 3},
+		{shards: 4, wantShards: 4},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Test case %v", i), func(t *testing.T) {
+			cache := NewCache(time.Minute, WithShards(c.shards), WithMaxEntries(c.maxEntries), WithMaxBytes(c.maxBytes))
+			defer cache.Close()
+
+			if len(cache.shards) != c.wantShards {
+				t.Fatalf("expected %d shards, got %d", c.wantShards, len(cache.shards))
+			}
+			entries, bytes := 0, 0
+			for _, s := range cache.shards {
+				if (c.maxEntries > 0 && s.maxEntries == 0) || (c.maxBytes > 0 && s.maxBytes == 0) {
+					t.Errorf("expected every shard to be limited, got %d entries and %d bytes", s.maxEntries, s.maxBytes)
+				}
+				entries += s.maxEntries
+				bytes += s.maxBytes
+			}
+			if entries != c.maxEntries || bytes != c.maxBytes {
+				t.Errorf("expected limits %d and %d, got %d and %d", c.maxEntries, c.maxBytes, entries, bytes)
+			}
+		})
+	}
+}
+
+func BenchmarkParallel1Shard(b *testing.B) {
+	benchmarkParallel(b, 1)
+}
+
+func BenchmarkParallel16Shards(b *testing.B) {
+	benchmarkParallel(b, 16)
 }
@@ -0,0 +1,136 @@
+package pokecache
+
+import "bytes"
+
+// Typed keeps decoded values next to the bytes in a Cache so repeated hits
+// skip decoding. The byte cache stays the source of truth: a decoded value
+// lives in the same entry and goes away when the bytes are replaced,
+// expire or are evicted.
+type Typed[V any] struct {
+	cache  *Cache
+	decode func([]byte) (V, error)
+}
+
+func NewTyped[V any](cache *Cache, decode func([]byte) (V, error)) *Typed[V] {
+	return &Typed[V]{
+		cache:  cache,
+		decode: decode,
+	}
+}
+
+// Get returns the decoded value for key if its bytes are cached and fresh,
+// decoding them only on the first hit.
+func (t *Typed[V]) Get(key string) (V, bool) {
+	return t.get(key, true)
+}
+
+// get is Get, counting a miss only if countMiss is set.
+func (t *Typed[V]) get(key string, countMiss bool) (V, bool) {
+	var zero V
+	val, decoded, gen, ok := t.cache.getDecoded(key, countMiss)
+	if !ok {
+		return zero, false
+	}
+	if v, ok := decoded.(V); ok {
+		return v, true
+	}
+
+	v, err := t.decode(val)
+	if err != nil {
+		return zero, false
+	}
+	t.cache.setDecoded(key, gen, v)
+	return v, true
+}
+
+// Load returns the decoded value for key, calling fetch for the bytes on a
+// miss. fetch is expected to store what it returns in the underlying
+// cache, as pokeapi.Client does, and so counts the miss itself.
+func (t *Typed[V]) Load(key string, fetch func() ([]byte, error)) (V, error) {
+	if v, ok := t.get(key, false); ok {
+		return v, nil
+	}
+
+	var zero V
+	val, err := fetch()
+	if err != nil {
+		return zero, err
+	}
+	v, err := t.decode(val)
+	if err != nil {
+		return zero, err
+	}
+	t.cache.attachDecoded(key, val, v)
+	return v, nil
+}
+
+func (c *Cache) getDecoded(key string, countMiss bool) ([]byte, any, uint64, bool) {
+	s := c.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.cachedEntry[key]
+	if !ok || c.expired(entry, c.clock.Now()) {
+		if countMiss {
+			s.misses++
+		}
+		return nil, nil, 0, false
+	}
+	s.recency.MoveToFront(entry.elem)
+	if entry.decoded != nil {
+		s.hits++
+		return nil, entry.decoded, entry.gen, true
+	}
+	exported, err := c.unpack(s, key, entry)
+	if err != nil {
+		if countMiss {
+			s.misses++
+		}
+		return nil, nil, 0, false
+	}
+	s.hits++
+	return exported.Val, nil, entry.gen, true
+}
+
+// setDecoded attaches decoded to key's entry, but only if the entry is
+// still the one it was decoded from.
+func (c *Cache) setDecoded(key string, gen uint64, decoded any) {
+	s := c.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.cachedEntry[key]
+	if !ok || entry.gen != gen {
+		return
+	}
+	entry.decoded = decoded
+	s.cachedEntry[key] = entry
+}
+
+// attachDecoded attaches decoded to key's entry if the entry holds val,
+// for values decoded from bytes that did not come out of the cache.
+func (c *Cache) attachDecoded(key string, val []byte, decoded any) {
+	s := c.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.cachedEntry[key]
+	if !ok {
+		return
+	}
+	if !sameBytes(entry.val, val) {
+		stored, err := c.unpack(s, key, entry)
+		if err != nil || !bytes.Equal(stored.Val, val) {
+			return
+		}
+		if current, ok := s.cachedEntry[key]; !ok || current.gen != entry.gen {
+			return
+		}
+	}
+	entry.decoded = decoded
+	s.cachedEntry[key] = entry
+}
+
+func sameBytes(a, b []byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	return len(a) == 0 || &a[0] == &b[0]
+}
@@ -0,0 +1,149 @@
+package sprite
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/png"
+	"io"
+)
+
+type ColorMode int
+
+const (
+	Color256 ColorMode = iota
+	TrueColor
+)
+
+func Decode(data []byte) (image.Image, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding image: %v", err)
+	}
+	return img, nil
+}
+
+// Trim returns the smallest rectangle containing every visible pixel, so
+// the transparent padding around sprites doesn't waste terminal space.
+func Trim(img image.Image) image.Rectangle {
+	bounds := img.Bounds()
+	trimmed := image.Rectangle{}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if !visible(img.At(x, y)) {
+				continue
+			}
+			trimmed = trimmed.Union(image.Rect(x, y, x+1, y+1))
+		}
+	}
+	return trimmed
+}
+
+// RenderANSI draws img with half-block characters, two pixels per cell,
+// scaled down to at most width columns.
+func RenderANSI(w io.Writer, img image.Image, width int, mode ColorMode) error {
+	src := scaled{img: img, rect: Trim(img), width: width}
+	cols, rows := src.size()
+
+	out := bufio.NewWriter(w)
+	for y := 0; y < rows; y += 2 {
+		for x := 0; x < cols; x++ {
+			top := src.at(x, y)
+			bottom := color.NRGBA{}
+			if y+1 < rows {
+				bottom = src.at(x, y+1)
+			}
+
+			switch {
+			case top.A == 0 && bottom.A == 0:
+				fmt.Fprint(out, "\x1b[0m ")
+			case bottom.A == 0:
+				fmt.Fprintf(out, "\x1b[0;%sm▀", colorCode(38, top, mode))
+			case top.A == 0:
+				fmt.Fprintf(out, "\x1b[0;%sm▄", colorCode(38, bottom, mode))
+			default:
+				fmt.Fprintf(out, "\x1b[0;%s;%sm▀", colorCode(38, top, mode), colorCode(48, bottom, mode))
+			}
+		}
+		fmt.Fprint(out, "\x1b[0m\n")
+	}
+	return out.Flush()
+}
+
+type scaled struct {
+	img   image.Image
+	rect  image.Rectangle
+	width int
+}
+
+func (s scaled) size() (int, int) {
+	cols, rows := s.rect.Dx(), s.rect.Dy()
+	if s.width > 0 && cols > s.width {
+		rows = rows * s.width / cols
+		cols = s.width
+	}
+	return cols, rows
+}
+
+func (s scaled) at(x, y int) color.NRGBA {
+	cols, rows := s.size()
+	px := s.rect.Min.X + x*s.rect.Dx()/cols
+	py := s.rect.Min.Y + y*s.rect.Dy()/rows
+	c := color.NRGBAModel.Convert(s.img.At(px, py)).(color.NRGBA)
+	if c.A < 128 {
+		return color.NRGBA{}
+	}
+	return c
+}
+
+func visible(c color.Color) bool {
+	_, _, _, a := c.RGBA()
+	return a >= 0x8000
+}
+
+func colorCode(layer int, c color.NRGBA, mode ColorMode) string {
+	if mode == TrueColor {
+		return fmt.Sprintf("%d;2;%d;%d;%d", layer, c.R, c.G, c.B)
+	}
+	return fmt.Sprintf("%d;5;%d", layer, xterm256(c))
+}
+
+var cubeLevels = [6]int{0, 95, 135, 175, 215, 255}
+
+// xterm256 maps a color to the closest entry in the 6x6x6 color cube or
+// the 24-step grayscale ramp of the xterm 256-color palette.
+func xterm256(c color.NRGBA) int {
+	r, g, b := int(c.R), int(c.G), int(c.B)
+
+	ri, gi, bi := cubeIndex(r), cubeIndex(g), cubeIndex(b)
+	cube := 16 + 36*ri + 6*gi + bi
+	cubeDist := distance(r, g, b, cubeLevels[ri], cubeLevels[gi], cubeLevels[bi])
+
+	avg := (r + g + b) / 3
+	grayIndex := 23
+	if avg < 238 {
+		grayIndex = max(0, (avg-3)/10)
+	}
+	level := 8 + 10*grayIndex
+	if distance(r, g, b, level, level, level) < cubeDist {
+		return 232 + grayIndex
+	}
+	return cube
+}
+
+func cubeIndex(v int) int {
+	if v < 48 {
+		return 0
+	}
+	if v < 115 {
+		return 1
+	}
+	return (v - 35) / 40
+}
+
+func distance(r1, g1, b1, r2, g2, b2 int) int {
+	dr, dg, db := r1-r2, g1-g2, b1-b2
+	return dr*dr + dg*dg + db*db
+}
@@ -0,0 +1,97 @@
+package sprite
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"strings"
+	"testing"
+)
+
+func loadFixture(t *testing.T) image.Image {
+	t.Helper()
+	data, err := os.ReadFile("testdata/fixture.png")
+	if err != nil {
+		t.Fatalf("error reading fixture: %v", err)
+	}
+	img, err := Decode(data)
+	if err != nil {
+		t.Fatalf("error decoding fixture: %v", err)
+	}
+	return img
+}
+
+func TestTrim(t *testing.T) {
+	img := loadFixture(t)
+	got := Trim(img)
+	want := image.Rect(1, 1, 5, 5)
+	if got != want {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestRenderANSI(t *testing.T) {
+	img := loadFixture(t)
+	red := "38;2;255;0;0"
+	blue := "38;2;0;0;255"
+
+	cases := []struct {
+		width int
+		want  []string
+	}{
+		{
+			width: 0,
+			want: []string{
+				strings.Repeat("\x1b[0;"+red+";48;2;255;0;0m▀", 4) + "\x1b[0m",
+				strings.Repeat("\x1b[0;"+blue+";48;2;0;0;255m▀", 3) + "\x1b[0;" + blue + "m▀\x1b[0m",
+			},
+		},
+		{
+			width: 2,
+			want: []string{
+				strings.Repeat("\x1b[0;"+red+";48;2;0;0;255m▀", 2) + "\x1b[0m",
+			},
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Test case %v", i), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := RenderANSI(&buf, img, c.width, TrueColor); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+			if len(got) != len(c.want) {
+				t.Fatalf("expected %d lines, got %d", len(c.want), len(got))
+			}
+			for j := range got {
+				if got[j] != c.want[j] {
+					t.Errorf("line %d: expected %q, got %q", j, c.want[j], got[j])
+				}
+			}
+		})
+	}
+}
+
+func TestXterm256(t *testing.T) {
+	cases := []struct {
+		c    color.NRGBA
+		want int
+	}{
+		{c: color.NRGBA{0, 0, 0, 255}, want: 16},
+		{c: color.NRGBA{255, 0, 0, 255}, want: 196},
+		{c: color.NRGBA{0, 0, 255, 255}, want: 21},
+		{c: color.NRGBA{255, 255, 255, 255}, want: 231},
+		{c: color.NRGBA{128, 128, 128, 255}, want: 244},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Test case %v", i), func(t *testing.T) {
+			if got := xterm256(c.c); got != c.want {
+				t.Errorf("expected %d, got %d", c.want, got)
+			}
+		})
+	}
+}
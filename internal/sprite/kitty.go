@@ -0,0 +1,44 @@
+package sprite
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"io"
+)
+
+const kittyChunkSize = 4096
+
+// Kitty sends the PNG bytes as-is using the Kitty graphics protocol,
+// split into the chunk size the protocol requires.
+type Kitty struct{}
+
+func (Kitty) Render(w io.Writer, data []byte) error {
+	_, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("error decoding image: %v", err)
+	}
+	if format != "png" {
+		return fmt.Errorf("kitty renderer needs png data, got %s", format)
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(data)
+	out := bufio.NewWriter(w)
+	for start := 0; start < len(encoded); start += kittyChunkSize {
+		end := min(start+kittyChunkSize, len(encoded))
+		more := 0
+		if end < len(encoded) {
+			more = 1
+		}
+
+		if start == 0 {
+			fmt.Fprintf(out, "\x1b_Ga=T,f=100,m=%d;%s\x1b\\", more, encoded[start:end])
+		} else {
+			fmt.Fprintf(out, "\x1b_Gm=%d;%s\x1b\\", more, encoded[start:end])
+		}
+	}
+	fmt.Fprint(out, "\n")
+	return out.Flush()
+}
@@ -0,0 +1,70 @@
+package sprite
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Renderer draws encoded image bytes, as fetched from the sprite URLs, to
+// a terminal.
+type Renderer interface {
+	Render(w io.Writer, data []byte) error
+}
+
+type ANSI struct {
+	Width int
+	Mode  ColorMode
+}
+
+func (a ANSI) Render(w io.Writer, data []byte) error {
+	img, err := Decode(data)
+	if err != nil {
+		return err
+	}
+	return RenderANSI(w, img, a.Width, a.Mode)
+}
+
+// New returns the renderer called name, or detects one from the
+// environment when name is "auto" or empty.
+func New(name string, width int, getenv func(string) string) (Renderer, error) {
+	if name == "" || name == "auto" {
+		name = Detect(getenv)
+	}
+
+	switch name {
+	case "kitty":
+		return Kitty{}, nil
+	case "sixel":
+		return Sixel{}, nil
+	case "ansi":
+		mode := Color256
+		switch strings.ToLower(getenv("COLORTERM")) {
+		case "truecolor", "24bit":
+			mode = TrueColor
+		}
+		return ANSI{Width: width, Mode: mode}, nil
+	}
+	return nil, fmt.Errorf("unknown renderer '%s'", name)
+}
+
+// Detect guesses the best graphics protocol from well-known terminal
+// environment variables, falling back to ANSI blocks.
+func Detect(getenv func(string) string) string {
+	term := getenv("TERM")
+	program := getenv("TERM_PROGRAM")
+
+	if getenv("KITTY_WINDOW_ID") != "" || term == "xterm-kitty" || term == "xterm-ghostty" {
+		return "kitty"
+	}
+	switch program {
+	case "WezTerm", "ghostty":
+		return "kitty"
+	case "iTerm.app", "mlterm":
+		return "sixel"
+	}
+	if term == "foot" || strings.HasPrefix(term, "mlterm") || strings.Contains(term, "sixel") {
+		return "sixel"
+	}
+	return "ansi"
+}
@@ -0,0 +1,119 @@
+package sprite
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	cases := []struct {
+		env  map[string]string
+		want string
+	}{
+		{env: map[string]string{}, want: "ansi"},
+		{env: map[string]string{"TERM": "xterm-256color"}, want: "ansi"},
+		{env: map[string]string{"KITTY_WINDOW_ID": "1"}, want: "kitty"},
+		{env: map[string]string{"TERM_PROGRAM": "WezTerm"}, want: "kitty"},
+		{env: map[string]string{"TERM": "foot"}, want: "sixel"},
+		{env: map[string]string{"TERM_PROGRAM": "iTerm.app"}, want: "sixel"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Test case %v", i), func(t *testing.T) {
+			getenv := func(key string) string { return c.env[key] }
+			if got := Detect(getenv); got != c.want {
+				t.Errorf("expected %s, got %s", c.want, got)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	getenv := func(key string) string {
+		if key == "COLORTERM" {
+			return "truecolor"
+		}
+		return ""
+	}
+
+	r, err := New("auto", 10, getenv)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r != (ANSI{Width: 10, Mode: TrueColor}) {
+		t.Errorf("expected truecolor ANSI renderer, got %#v", r)
+	}
+
+	if _, err := New("braille", 10, getenv); err == nil {
+		t.Errorf("expected error for unknown renderer")
+	}
+}
+
+func TestSixel(t *testing.T) {
+	data, err := os.ReadFile("testdata/fixture.png")
+	if err != nil {
+		t.Fatalf("error reading fixture: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := (Sixel{}).Render(&buf, data); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "\x1bP0;1q\"1;1;4;4#180;2;100;0;0#180!4B#5;2;0;0;100$#5KKKC-\x1b\\\n"
+	if buf.String() != want {
+		t.Errorf("expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestKitty(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	seed := uint32(1)
+	for i := range img.Pix {
+		seed = seed*1103515245 + 12345
+		img.Pix[i] = byte(seed >> 16)
+	}
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, img); err != nil {
+		t.Fatalf("error encoding image: %v", err)
+	}
+	data := encoded.Bytes()
+
+	var buf bytes.Buffer
+	if err := (Kitty{}).Render(&buf, data); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	chunks := strings.Split(strings.TrimSuffix(buf.String(), "\x1b\\\n"), "\x1b\\")
+	want := (base64.StdEncoding.EncodedLen(len(data)) + kittyChunkSize - 1) / kittyChunkSize
+	if want < 2 {
+		t.Fatalf("fixture too small to test chunking")
+	}
+	if len(chunks) != want {
+		t.Fatalf("expected %d chunks, got %d", want, len(chunks))
+	}
+	if !strings.HasPrefix(chunks[0], "\x1b_Ga=T,f=100,m=1;") {
+		t.Errorf("unexpected first chunk header: %q", chunks[0][:20])
+	}
+	if !strings.HasPrefix(chunks[len(chunks)-1], "\x1b_Gm=0;") {
+		t.Errorf("expected last chunk to end transmission")
+	}
+
+	var payload strings.Builder
+	for _, chunk := range chunks {
+		payload.WriteString(chunk[strings.Index(chunk, ";")+1:])
+	}
+	if payload.String() != base64.StdEncoding.EncodeToString(data) {
+		t.Errorf("payload does not match image data")
+	}
+
+	if err := (Kitty{}).Render(&buf, []byte("not an image")); err == nil {
+		t.Errorf("expected error for invalid image")
+	}
+}
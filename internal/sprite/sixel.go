@@ -0,0 +1,88 @@
+package sprite
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"io"
+	"strings"
+)
+
+// Sixel draws the image at full resolution using the DEC Sixel protocol,
+// quantized to the 216-color cube. Transparent pixels are left unpainted.
+type Sixel struct{}
+
+func (Sixel) Render(w io.Writer, data []byte) error {
+	img, err := Decode(data)
+	if err != nil {
+		return err
+	}
+	rect := Trim(img)
+	width := rect.Dx()
+
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "\x1bP0;1q\"1;1;%d;%d", width, rect.Dy())
+
+	defined := make(map[int]bool)
+	for band := rect.Min.Y; band < rect.Max.Y; band += 6 {
+		rows := make(map[int][]byte)
+		order := []int{}
+		for dy := 0; dy < 6 && band+dy < rect.Max.Y; dy++ {
+			for x := rect.Min.X; x < rect.Max.X; x++ {
+				c := color.NRGBAModel.Convert(img.At(x, band+dy)).(color.NRGBA)
+				if c.A < 128 {
+					continue
+				}
+				index := 36*cubeIndex(int(c.R)) + 6*cubeIndex(int(c.G)) + cubeIndex(int(c.B))
+				row, ok := rows[index]
+				if !ok {
+					row = make([]byte, width)
+					rows[index] = row
+					order = append(order, index)
+				}
+				row[x-rect.Min.X] |= 1 << dy
+			}
+		}
+
+		for i, index := range order {
+			if !defined[index] {
+				r, g, b := cubeLevels[index/36], cubeLevels[index/6%6], cubeLevels[index%6]
+				fmt.Fprintf(out, "#%d;2;%d;%d;%d", index, r*100/255, g*100/255, b*100/255)
+				defined[index] = true
+			}
+			if i > 0 {
+				out.WriteByte('$')
+			}
+			fmt.Fprintf(out, "#%d%s", index, sixelRow(rows[index]))
+		}
+		out.WriteByte('-')
+	}
+
+	fmt.Fprint(out, "\x1b\\\n")
+	return out.Flush()
+}
+
+// sixelRow encodes one color's bits for a band, run-length compressing
+// repeats and dropping trailing empty columns.
+func sixelRow(bits []byte) string {
+	end := len(bits)
+	for end > 0 && bits[end-1] == 0 {
+		end--
+	}
+
+	var sb strings.Builder
+	for i := 0; i < end; {
+		j := i
+		for j < end && bits[j] == bits[i] {
+			j++
+		}
+		ch := rune(63 + bits[i])
+		if run := j - i; run > 3 {
+			fmt.Fprintf(&sb, "!%d%c", run, ch)
+		} else {
+			sb.WriteString(strings.Repeat(string(ch), run))
+		}
+		i = j
+	}
+	return sb.String()
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+const fallbackLang = "en"
+
+type localizedNames []struct {
+	Language struct {
+		Name string `json:"name"`
+		URL  string `json:"url"`
+	} `json:"language"`
+	Name string `json:"name"`
+}
+
+type pokemonSpecies struct {
+	ID                int            `json:"id"`
+	Name              string         `json:"name"`
+	Names             localizedNames `json:"names"`
+	FlavorTextEntries []struct {
+		FlavorText string `json:"flavor_text"`
+		Language   struct {
+			Name string `json:"name"`
+			URL  string `json:"url"`
+		} `json:"language"`
+		Version struct {
+			Name string `json:"name"`
+			URL  string `json:"url"`
+		} `json:"version"`
+	} `json:"flavor_text_entries"`
+}
+
+func commandLang(ctx context.Context, c *config, name ...string) error {
+	if len(name) == 0 {
+		if c.lang == "" {
+			fmt.Fprintln(c.out, "No language set, showing resource names")
+			return nil
+		}
+		fmt.Fprintf(c.out, "Language: %s\n", c.lang)
+		return nil
+	}
+
+	if name[0] == "off" {
+		c.lang = ""
+		fmt.Fprintln(c.out, "Showing resource names")
+		return nil
+	}
+
+	c.lang = name[0]
+	fmt.Fprintf(c.out, "Language set to %s\n", c.lang)
+	return nil
+}
+
+func (names localizedNames) get(lang, fallback string) string {
+	if lang == "" {
+		return fallback
+	}
+
+	english := ""
+	for _, item := range names {
+		if item.Language.Name == lang {
+			return item.Name
+		}
+		if item.Language.Name == fallbackLang {
+			english = item.Name
+		}
+	}
+	if english != "" {
+		return english
+	}
+	return fallback
+}
+
+func fetchSpecies(ctx context.Context, c *config, url string) (pokemonSpecies, error) {
+	var species pokemonSpecies
+	body, err := fetch(ctx, c, url)
+	if err != nil {
+		return species, err
+	}
+
+	if err := json.Unmarshal(body, &species); err != nil {
+		return species, fmt.Errorf("error parsing response: %v", err)
+	}
+	return species, nil
+}
+
+// maxLookups caps how many names localize looks up at once.
+const maxLookups = 4
+
+// localize translates names with lookup, a few at a time, keeping their
+// order. Without a language it returns names as they are. Lookups go
+// through the cache, so names shared by several items are fetched once.
+func localize(ctx context.Context, c *config, names []string, lookup func(context.Context, *config, string) (string, error)) ([]string, error) {
+	if c.lang == "" {
+		return names, nil
+	}
+
+	localized := make([]string, len(names))
+	errs := make([]error, len(names))
+	slots := make(chan struct{}, maxLookups)
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
+			}
+			defer func() { <-slots }()
+			localized[i], errs[i] = lookup(ctx, c, name)
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return localized, nil
+}
+
+// locationAreaName returns the area's name in c.lang. A name that can't
+// be fetched falls back to name; only a cancelled ctx is an error.
+func locationAreaName(ctx context.Context, c *config, name string) (string, error) {
+	body, err := fetch(ctx, c, c.apiBase+"location-area/"+name)
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+	if err != nil {
+		return name, nil
+	}
+
+	var area areaPokemon
+	if err := json.Unmarshal(body, &area); err != nil {
+		return name, nil
+	}
+	return localizedNames(area.Names).get(c.lang, name), nil
+}
+
+// pokemonName returns the name in c.lang of the species of the Pokemon
+// called name, which for forms such as deoxys-normal differs from name.
+func pokemonName(ctx context.Context, c *config, name string) (string, error) {
+	pokemon, err := fetchPokemon(ctx, c, name)
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+	if err != nil {
+		return name, nil
+	}
+	return speciesName(ctx, c, pokemon.Species.Name)
+}
+
+// speciesName returns the name of a species in c.lang.
+func speciesName(ctx context.Context, c *config, name string) (string, error) {
+	species, err := fetchSpecies(ctx, c, c.apiBase+"pokemon-species/"+name)
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+	if err != nil {
+		return name, nil
+	}
+	return species.Names.get(c.lang, name), nil
+}
+
+func flavorText(species pokemonSpecies, lang, version string) string {
+	for _, want := range []string{lang, fallbackLang} {
+		text := ""
+		for _, entry := range species.FlavorTextEntries {
+			if entry.Language.Name != want {
+				continue
+			}
+			if version == "" || entry.Version.Name == version {
+				text = entry.FlavorText
+				break
+			}
+			if text == "" {
+				text = entry.FlavorText
+			}
+		}
+		if text != "" {
+			return strings.Join(strings.Fields(text), " ")
+		}
+	}
+	return ""
+}
@@ -2,26 +2,25 @@ package main
 
 import (
 	"bufio"
+	"compress/gzip"
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"math/rand"
-	"net/http"
 	"os"
+	"os/signal"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/Lusbox/Pokedex/internal/pokeapi"
 	"github.com/Lusbox/Pokedex/internal/pokecache"
+	"github.com/Lusbox/Pokedex/internal/sprite"
 )
 
-type cliCommand struct {
-	name        string
-	description string
-	callback    func(*config, ...string) error
-}
-
-var commands map[string]cliCommand
-
 var myPokedex map[string]pokemonDetails
 
 type maplocations struct {
@@ -342,311 +341,549 @@ type pokemonDetails struct {
 }
 
 type config struct {
-	Next string
-	Previous string
-	cache *pokecache.Cache
+	Next         string
+	Previous     string
+	apiBase      string
+	cache        *pokecache.Cache
+	client       *pokeapi.Client
+	pokemon      *pokecache.Typed[pokemonDetails]
+	version      string
+	versionGroup string
+	lang         string
+	renderer     sprite.Renderer
+	dump         string
+	saveDir      string
+	theme        theme
+	format       string
+	prompt       string
+	settingsPath string
+	settings     settings
+	// flags holds the settings given as flags, which override the config
+	// file for this session.
+	flags     map[string]string
+	catchRate int
+	commands  *registry
+	depth     int
+	// rng decides every random outcome in a session, so a session started
+	// with the same seed plays out the same way.
+	rng        *rand.Rand
+	out        io.Writer
+	interrupts <-chan os.Signal
 }
 
+const defaultAPIBase = "https://pokeapi.co/api/v2/"
+
 func main() {
+	apiBaseFlag := flag.String("api-base", defaultAPIBase, "PokeAPI base URL")
+	versionFlag := flag.String("version", "", "game version to filter by (e.g. red, emerald)")
+	langFlag := flag.String("lang", "", "language code for display names (e.g. de, ja)")
+	renderFlag := flag.String("render", "auto", "sprite output: auto, ansi, sixel or kitty")
+	rpsFlag := flag.Float64("rps", 5, "maximum PokeAPI requests per second (0 to disable)")
+	burstFlag := flag.Int("burst", 10, "number of PokeAPI requests allowed in a burst")
+	cacheTTLFlag := flag.Duration("cache-ttl", defaultCacheTTL, "how long list pages such as map results stay in the cache")
+	cacheMBFlag := flag.Int("cache-mb", defaultCacheMB, "maximum size of the response cache in megabytes")
+	compressFlag := flag.Bool("cache-compress", true, "gzip cached responses to fit more in memory")
+	offlineFlag := flag.Bool("offline", false, "serve every request from the dump instead of the network")
+	seedFlag := &seedValue{}
+	flag.Var(seedFlag, "seed", "seed catch rolls with `number` to replay a session exactly (random if not given)")
+	saveDirFlag := flag.String("save-dir", "", "directory cry saves to (current directory if empty)")
+	themeFlag := flag.String("theme", defaultTheme, "color theme: none, dark or light")
+	formatFlag := flag.String("format", defaultFormat, "output format of inspect and pokedex: text or json")
+	promptFlag := flag.String("prompt", defaultPrompt, "REPL prompt")
+	catchRateFlag := flag.Int("catch-rate", defaultCatchRate, "a Pokemon is caught when a roll below its base experience is under this")
+	configFlag := flag.String("config", defaultSettingsPath(), "file that keeps settings, aliases and macros")
+	dumpFlag := flag.String("dump", "pokeapi-dump", "directory or zip archive of PokeAPI data, filled by sync")
+	flag.Parse()
+	givenFlags := givenSettings(flag.CommandLine)
+
+	fileSettings, err := loadSettings(*configFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error: ", err)
+		os.Exit(1)
+	}
+	if err := applySettings(flag.CommandLine, fileSettings); err != nil {
+		fmt.Fprintln(os.Stderr, "Error: ", err)
+		os.Exit(1)
+	}
+
+	renderer, err := sprite.New(*renderFlag, spriteWidth, os.Getenv)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error: ", err)
+		os.Exit(1)
+	}
+
+	seed := seedFlag.seed
+	if !seedFlag.set {
+		seed = time.Now().UnixNano()
+	}
+
 	myPokedex = make(map[string]pokemonDetails)
+	cacheOpts := []pokecache.Option{
+		pokecache.WithTTL(*cacheTTLFlag),
+		pokecache.WithPolicies(pokeapi.CachePolicies...),
+		pokecache.WithMaxBytes(*cacheMBFlag << 20),
+	}
+	if *compressFlag {
+		cacheOpts = append(cacheOpts, pokecache.WithCompression(gzip.BestSpeed))
+	}
+	cache := pokecache.NewCache(time.Minute, cacheOpts...)
+	apiBase := strings.TrimSuffix(*apiBaseFlag, "/") + "/"
 	cfg := &config{
-		Next:     "https://pokeapi.co/api/v2/location-area/",
-		Previous: "",
-		cache:    pokecache.NewCache(5 * time.Minute),
-	}
-
-	commands = map[string]cliCommand{
-		"exit": {
-			name:        "exit",
-			description: "Exit the Pokedex",
-			callback:    commandExit,
-		},
-		"help": {
-			name:        "help",
-			description: "Displays the help message",
-			callback:    commandHelp,
-		},
-		"map": {
-			name:        "map",
-			description: "Display locations",
-			callback:    commandMap,
-		},
-		"mapb": {
-			name:        "mapb",
-			description: "Previous locations",
-			callback:    commandMapb,
-		},
-		"explore": {
-			name:        "explore",
-			description: "Add area name to show Pokemon found",
-			callback:    commmandExplore,
-		},
-		"catch": {
-			name:        "catch",
-			description: "Attempt to catch Pokemon",
-			callback:    commandCatch,
-		},
-		"inspect": {
-			name:		 "inspect",
-			description: "Show Pokemon details",
-			callback: 	 commandInspect,
-		},
-		"pokedex": {
-			name: "pokedex",
-			description: "List Pokemon you have caught",
-			callback: nil,
-		},
-	}
-
-	input := bufio.NewScanner(os.Stdin)
+		Next:         apiBase + "location-area/",
+		Previous:     "",
+		apiBase:      apiBase,
+		cache:        cache,
+		client:       pokeapi.NewClient(cache, 10*time.Second),
+		pokemon:      pokecache.NewTyped(cache, decodePokemon),
+		lang:         *langFlag,
+		renderer:     renderer,
+		dump:         *dumpFlag,
+		saveDir:      *saveDirFlag,
+		theme:        themes[*themeFlag],
+		format:       *formatFlag,
+		prompt:       *promptFlag,
+		settingsPath: *configFlag,
+		settings:     fileSettings,
+		flags:        givenFlags,
+		catchRate:    *catchRateFlag,
+		commands:     commands.clone(),
+		rng:          rand.New(rand.NewSource(seed)),
+		out:          os.Stdout,
+	}
+	cfg.client.StaleWhileRevalidate = true
+	if *rpsFlag > 0 {
+		cfg.client.Limiter = pokeapi.NewLimiter(*rpsFlag, *burstFlag)
+		cfg.client.OnWait = func(wait time.Duration) {
+			fmt.Fprintf(cfg.out, "(rate limited, waiting %v)\n", wait.Round(time.Millisecond))
+		}
+	}
+	recorder, err := pokeapi.RecorderFromEnv(os.Getenv)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error: ", err)
+		os.Exit(1)
+	}
+	if recorder != nil {
+		cfg.client.SetTransport(recorder)
+	}
+	if *offlineFlag {
+		dump, err := pokeapi.OpenDump(*dumpFlag)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error: ", err)
+			os.Exit(1)
+		}
+		cfg.client.Dump = dump
+	}
 
-	for {
-		fmt.Print("Pokedex > ")
-		if input.Scan() {
-			words := strings.Fields(input.Text())
-			if len(words) == 0 {
-				continue
-			}
+	registerUserCommands(cfg)
+
+	if *versionFlag != "" {
+		startVersion(context.Background(), cfg, *versionFlag)
+	}
+
+	fmt.Fprintf(cfg.out, "Seed: %d (replay this session with --seed %d)\n", seed, seed)
 
-			commandName := words[0]
-			args := words[1:]
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	cfg.interrupts = interrupts
 
-			c, ok := commands[commandName]
+	if err := cfg.Run(context.Background(), os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "Error: ", err)
+		os.Exit(1)
+	}
+}
+
+// errExit is returned by commandExit to end the REPL.
+var errExit = errors.New("exit")
+
+// Run reads commands from in and writes their output to out until the exit
+// command, the end of in, two interrupts in a row at the prompt or ctx
+// is done. A single interrupt at the prompt drops the line being typed and
+// prompts again, since the terminal doesn't say whether anything was typed.
+func (c *config) Run(ctx context.Context, in io.Reader, out io.Writer) error {
+	c.out = out
+	done := make(chan struct{})
+	defer close(done)
+	lines := readLines(in, done)
+
+	interrupted := false
+	for {
+		prompt := c.prompt
+		if prompt == "" {
+			prompt = defaultPrompt
+		}
+		fmt.Fprint(c.out, c.theme.paint(c.theme.prompt, prompt)+" ")
+		var line string
+		select {
+		case l, ok := <-lines:
 			if !ok {
-				fmt.Println("Unknown command")
-				continue
+				fmt.Fprintln(c.out)
+				commandExit(ctx, c)
+				return nil
 			}
-			if commandName == "pokedex" {
-				pokedex()
-				continue
+			line = l
+			interrupted = false
+		case <-c.interrupts:
+			fmt.Fprintln(c.out)
+			if interrupted {
+				commandExit(ctx, c)
+				return nil
 			}
+			fmt.Fprintln(c.out, "(press Ctrl-C again or type exit to quit)")
+			interrupted = true
+			continue
+		case <-ctx.Done():
+			return ctx.Err()
+		}
 
-			err := c.callback(cfg, args...)
-			if err != nil {
-				fmt.Println("Error: ", err)
-			}
+		words := strings.Fields(line)
+		if len(words) == 0 {
+			continue
 		}
-	}
-}
 
-func commandExit(c *config, name ...string) error {
-	fmt.Println("Closing the Pokedex... Goodbye!")
-	os.Exit(0)
-	return nil
+		if _, ok := c.commands.lookup(words[0]); !ok {
+			fmt.Fprintln(c.out, "Unknown command")
+			continue
+		}
+
+		err := runCommand(ctx, c.interrupts, func(ctx context.Context) error {
+			return c.execute(ctx, words)
+		})
+		if errors.Is(err, errExit) {
+			return nil
+		} else if errors.Is(err, context.Canceled) {
+			fmt.Fprintln(c.out, "Command cancelled")
+		} else if err != nil {
+			fmt.Fprintln(c.out, c.theme.paint(c.theme.err, "Error: "), err)
+		}
+	}
 }
 
-func commandHelp(c *config, name ...string) error {
-	fmt.Println("Welcome to the Pokedex!")
-	fmt.Println("Usage:")
-	fmt.Println()
-	for _, value := range commands {
-		msg := fmt.Sprintf("%s: %s", value.name, value.description)
-		fmt.Println(msg)
+// execute runs one command line, checking its arguments first. Aliases
+// and macros run their commands through it too.
+func (c *config) execute(ctx context.Context, words []string) error {
+	if len(words) == 0 {
+		return nil
 	}
-	return nil
+	command, ok := c.commands.lookup(words[0])
+	if !ok {
+		return fmt.Errorf("unknown command '%s'", words[0])
+	}
+	if err := command.checkArgs(words[1:]); err != nil {
+		return err
+	}
+	if c.depth >= maxDepth {
+		return errTooDeep
+	}
+	c.depth++
+	defer func() { c.depth-- }()
+	return command.callback(ctx, c, words[1:]...)
 }
 
-func commandMap(c *config, name ...string) error {
-	cachedData, ok := c.cache.Get(c.Next)
+// readLines sends the lines of r until it ends or done is closed. A read
+// that is blocked when done is closed still has to return before the
+// goroutine can exit, but nothing is sent after that.
+func readLines(r io.Reader, done <-chan struct{}) <-chan string {
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		input := bufio.NewScanner(r)
+		for input.Scan() {
+			select {
+			case lines <- input.Text():
+			case <-done:
+				return
+			}
+		}
+	}()
+	return lines
+}
 
-	var body []byte
-	if ok {
-		body = cachedData
-	} else {
-		res, err := http.Get(c.Next)
-		if err != nil {
-			return fmt.Errorf("error getting response: %v", err)
+// runCommand runs a command with a context that is cancelled when the user
+// presses Ctrl-C, so in-flight requests are aborted and the REPL continues.
+func runCommand(ctx context.Context, interrupts <-chan os.Signal, run func(context.Context) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-interrupts:
+			cancel()
+		case <-done:
 		}
+	}()
+	return run(ctx)
+}
 
-		defer res.Body.Close()
+func commandExit(ctx context.Context, c *config, name ...string) error {
+	fmt.Fprintln(c.out, "Closing the Pokedex... Goodbye!")
+	c.client.Close()
+	c.cache.Close()
+	if c.client.Dump != nil {
+		c.client.Dump.Close()
+	}
+	return errExit
+}
 
-		var err2 error
-		body, err2 = io.ReadAll(res.Body)
-		if res.StatusCode > 299 {
-			return fmt.Errorf("error with statuscode: %v", res.StatusCode)
-		}
-		if err2 != nil {
-			return fmt.Errorf("error reading body")
-		}
-		c.cache.Add(c.Next, body)
+func commandMap(ctx context.Context, c *config, name ...string) error {
+	body, err := fetch(ctx, c, c.Next)
+	if err != nil {
+		return err
 	}
 
 	var locations maplocations
-	err := json.Unmarshal(body, &locations)
+	err = json.Unmarshal(body, &locations)
 	if err != nil {
 		return fmt.Errorf("data not json format: %v", err)
 	}
 
+	names := make([]string, 0, len(locations.Results))
+	for _, item := range locations.Results {
+		names = append(names, item.Name)
+	}
+	names, err = localize(ctx, c, names, locationAreaName)
+	if err != nil {
+		return err
+	}
+
 	c.Next = locations.Next
 	c.Previous = locations.Previous
 
-	for _, item := range locations.Results {
-		fmt.Println(item.Name)
+	for _, name := range names {
+		fmt.Fprintln(c.out, name)
 	}
 
 	return nil
 }
 
-func commandMapb(c *config, name ...string) error {
+func commandMapb(ctx context.Context, c *config, name ...string) error {
 	if c.Previous == "" {
-		fmt.Println("you're on the first page")
+		fmt.Fprintln(c.out, "you're on the first page")
 		return nil
 	}
 
-	cachedData, ok := c.cache.Get(c.Previous)
-
-	var body []byte
-	if ok {
-		body = cachedData
-	} else {
-		res, err := http.Get(c.Previous)
-		if err != nil {
-			return fmt.Errorf("error getting response: %v", err)
-		}
-
-		defer res.Body.Close()
-
-		body, err = io.ReadAll(res.Body)
-		if res.StatusCode > 299 {
-			return fmt.Errorf("error with statuscode: %v", res.StatusCode)
-		}
-		if err != nil {
-			return fmt.Errorf("error reading body")
-		}
-
-		c.cache.Add(c.Previous, body)
+	body, err := fetch(ctx, c, c.Previous)
+	if err != nil {
+		return err
 	}
 
 	var locations maplocations
-	err := json.Unmarshal(body, &locations)
+	err = json.Unmarshal(body, &locations)
 	if err != nil {
 		return fmt.Errorf("data not json format: %v", err)
 	}
 
+	names := make([]string, 0, len(locations.Results))
+	for _, item := range locations.Results {
+		names = append(names, item.Name)
+	}
+	names, err = localize(ctx, c, names, locationAreaName)
+	if err != nil {
+		return err
+	}
+
 	c.Next = locations.Next
 	c.Previous = locations.Previous
 
-	for _, item := range locations.Results {
-		fmt.Println(item.Name)
+	for _, name := range names {
+		fmt.Fprintln(c.out, name)
 	}
 
 	return nil
 }
 
-func commmandExplore(c *config, name ...string) error {
-	if len(name) == 0 || name[0] == "" {
-		return fmt.Errorf("please provide a location area name")
+func commmandExplore(ctx context.Context, c *config, name ...string) error {
+	body, err := fetch(ctx, c, c.apiBase+"location-area/"+name[0])
+	if errors.Is(err, pokeapi.ErrNotFound) {
+		return fmt.Errorf("location area '%s' not found", name[0])
 	}
-
-	url := "https://pokeapi.co/api/v2/location-area/" + name[0]
-	cachedData, ok := c.cache.Get(url)
-
-	var body []byte
-	if ok {
-		body = cachedData
-	} else {
-		res, err := http.Get(url)
-		if err != nil {
-			return fmt.Errorf("error getting response: %v", err)
-		}
-
-		defer res.Body.Close()
-
-		var err2 error
-		body, err2 = io.ReadAll(res.Body)
-		if err2 != nil {
-			return fmt.Errorf("error reading body: %v", err2)
-		}
-
-		c.cache.Add(url, body)
+	if err != nil {
+		return err
 	}
 
 	var pokemon areaPokemon
 	if err := json.Unmarshal(body, &pokemon); err != nil {
-		if strings.Contains(string(body), "Not Found") {
-			return fmt.Errorf("location area '%s' not found", name[0])
-		}
 		return fmt.Errorf("error parsing response: %v", err)
 	}
 
-	fmt.Printf("Exploring %s...\n", name[0])
-	fmt.Println("Found Pokemon:")
-
+	found := []string{}
 	for _, item := range pokemon.PokemonEncounters {
-		fmt.Printf(" - %s\n", item.Pokemon.Name)
+		if c.version != "" {
+			inVersion := false
+			for _, detail := range item.VersionDetails {
+				if detail.Version.Name == c.version {
+					inVersion = true
+					break
+				}
+			}
+			if !inVersion {
+				continue
+			}
+		}
+		found = append(found, item.Pokemon.Name)
 	}
-	return nil
-}
-
-func commandCatch(c *config, name ...string) error {
-	url := "https://pokeapi.co/api/v2/pokemon/" + name[0]
-
-	res, err := http.Get(url)
+	found, err = localize(ctx, c, found, pokemonName)
 	if err != nil {
-		return fmt.Errorf("error getting response:  %v", err)
+		return err
 	}
 
-	defer res.Body.Close()
-
-	var err2 error
-	body, err2 := io.ReadAll(res.Body)
-	if err2 != nil {
-		return fmt.Errorf("error reading body: %s", err2)
+	fmt.Fprintf(c.out, "Exploring %s...\n", localizedNames(pokemon.Names).get(c.lang, name[0]))
+	fmt.Fprintln(c.out, "Found Pokemon:")
+	for _, name := range found {
+		fmt.Fprintf(c.out, " - %s\n", name)
 	}
+	return nil
+}
 
-	var pokemon pokemonDetails
-	if err := json.Unmarshal(body, &pokemon); err != nil {
-		if strings.Contains(string(body), "Not Found") {
-			return fmt.Errorf("pokemon '%s' not found", name[0])
-		}
-		return fmt.Errorf("error parsing response: %v", err)
+func commandCatch(ctx context.Context, c *config, name ...string) error {
+	if len(name) == 0 || name[0] == "" {
+		return fmt.Errorf("please provide a Pokemon name")
 	}
 
-	
+	pokemon, err := fetchPokemon(ctx, c, name[0])
+	if err != nil {
+		return err
+	}
 
-	fmt.Printf("Throwing a Pokeball at %s...\n", name[0])
+	fmt.Fprintf(c.out, "Throwing a Pokeball at %s...\n", name[0])
 
-	attempt := rand.Intn(pokemon.BaseExperience)
-	if attempt < 20  {
-		fmt.Printf("%s was caught!\n", name[0])
-		fmt.Printf("Adding %s to Pokedex\n", name[0])
+	// PokeAPI leaves base_experience out for some Pokemon, and Intn
+	// panics on 0, so those are always caught
+	if pokemon.BaseExperience <= 0 || c.rng.Intn(pokemon.BaseExperience) < c.catchRate {
+		fmt.Fprintf(c.out, "%s was caught!\n", name[0])
+		fmt.Fprintf(c.out, "Adding %s to Pokedex\n", name[0])
 		myPokedex[name[0]] = pokemon
 	} else {
-		fmt.Printf("%s escaped!\n", name[0])
+		fmt.Fprintf(c.out, "%s escaped!\n", name[0])
 		return nil
 	}
 	return nil
 
 }
 
-func commandInspect(c *config, name ...string) error {
+func commandInspect(ctx context.Context, c *config, name ...string) error {
+	if len(name) == 0 || name[0] == "" {
+		return fmt.Errorf("please provide a Pokemon name")
+	}
+
+	draw, shiny := false, false
+	for _, arg := range name[1:] {
+		switch arg {
+		case "--sprite":
+			draw = true
+		case "--shiny":
+			draw, shiny = true, true
+		default:
+			return fmt.Errorf("unknown flag '%s'", arg)
+		}
+		if c.format == "json" {
+			return fmt.Errorf("%s can't be shown in the json format", arg)
+		}
+	}
+
 	item, ok := myPokedex[name[0]]
 	if !ok {
-		fmt.Println("you have not caught this Pokemon")
+		fmt.Fprintln(c.out, "you have not caught this Pokemon")
 		return nil
 	}
 
-	fmt.Printf("Name: %s\n", item.Name)
-	fmt.Printf("Height: %d\n", item.Height)
-	fmt.Printf("Weight: %d\n", item.Weight)
-	fmt.Println("Stats:")
+	displayName, text := item.Name, ""
+	if c.lang != "" {
+		species, err := fetchSpecies(ctx, c, c.apiBase+"pokemon-species/"+item.Species.Name)
+		if err != nil {
+			return err
+		}
+		displayName = species.Names.get(c.lang, item.Name)
+		text = flavorText(species, c.lang, c.version)
+	}
+
+	if c.format == "json" {
+		if err := writeJSON(c, inspectJSON(item, displayName, text)); err != nil {
+			return err
+		}
+	} else {
+		printInspect(c, item, displayName, text)
+	}
+
+	if draw {
+		return showSprite(ctx, c, item, shiny)
+	}
+	return nil
+}
+
+func printInspect(c *config, item pokemonDetails, displayName, text string) {
+	fmt.Fprintf(c.out, "Name: %s\n", displayName)
+	if text != "" {
+		fmt.Fprintln(c.out, text)
+	}
+	fmt.Fprintf(c.out, "Height: %d\n", item.Height)
+	fmt.Fprintf(c.out, "Weight: %d\n", item.Weight)
+	fmt.Fprintln(c.out, "Stats:")
+	for _, field := range item.Stats {
+		fmt.Fprintf(c.out, " - %s: %d\n", field.Stat.Name, field.BaseStat)
+	}
+	fmt.Fprintln(c.out, "Types:")
+	for _, field := range item.Types {
+		fmt.Fprintf(c.out, " - %s\n", field.Type.Name)
+	}
+}
+
+// inspectJSON is what inspect prints with the json output format.
+func inspectJSON(item pokemonDetails, displayName, text string) any {
+	stats := make(map[string]int, len(item.Stats))
 	for _, field := range item.Stats {
-		fmt.Printf(" - %s: %d\n", field.Stat.Name, field.BaseStat)
+		stats[field.Stat.Name] = field.BaseStat
 	}
-	fmt.Println("Types:")
+	types := make([]string, 0, len(item.Types))
 	for _, field := range item.Types {
-		fmt.Printf(" - %s\n", field.Type.Name)
+		types = append(types, field.Type.Name)
 	}
+	return struct {
+		Name       string         `json:"name"`
+		FlavorText string         `json:"flavor_text,omitempty"`
+		Height     int            `json:"height"`
+		Weight     int            `json:"weight"`
+		Stats      map[string]int `json:"stats"`
+		Types      []string       `json:"types"`
+	}{displayName, text, item.Height, item.Weight, stats, types}
+}
 
+func commandPokedex(ctx context.Context, c *config, name ...string) error {
+	keys := make([]string, 0, len(myPokedex))
+	for key := range myPokedex {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if c.lang != "" {
+		species := make([]string, len(keys))
+		for i, key := range keys {
+			species[i] = myPokedex[key].Species.Name
+		}
+		var err error
+		keys, err = localize(ctx, c, species, speciesName)
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.format == "json" {
+		return writeJSON(c, keys)
+	}
+	fmt.Fprintln(c.out, "Your Pokedex:")
+	if len(keys) == 0 {
+		fmt.Fprintln(c.out, "You have not caught any Pokemon")
+	}
+	for _, key := range keys {
+		fmt.Fprintf(c.out, " - %s\n", key)
+	}
 	return nil
 }
 
-func pokedex() {
-	fmt.Println("Your Pokedex:")
-	if len(myPokedex) == 0 {
-		fmt.Println("You have not caught any Pokemon")
-	}
-	for key, _ := range myPokedex {
-		fmt.Printf(" - %s\n", key)
+func fetch(ctx context.Context, c *config, url string) ([]byte, error) {
+	body, err := c.client.Get(ctx, url)
+	if errors.Is(err, pokeapi.ErrNotInDump) {
+		return nil, fmt.Errorf("%w (run sync while online to add it)", err)
 	}
+	return body, err
 }
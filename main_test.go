@@ -0,0 +1,513 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Lusbox/Pokedex/internal/fakeapi"
+	"github.com/Lusbox/Pokedex/internal/pokeapi"
+	"github.com/Lusbox/Pokedex/internal/pokecache"
+	"github.com/Lusbox/Pokedex/internal/sprite"
+)
+
+func newTestConfig(t *testing.T) (*config, *fakeapi.Server) {
+	server := fakeapi.New()
+	t.Cleanup(server.Close)
+	cache := pokecache.NewCache(time.Minute)
+	t.Cleanup(cache.Close)
+	client := pokeapi.NewClient(cache, time.Second)
+	t.Cleanup(client.Close)
+	client.BaseDelay = time.Millisecond
+	client.MaxDelay = time.Millisecond
+
+	// with seed 11 pikachu escapes the first ball and is caught by the second
+	rng := rand.New(rand.NewSource(11))
+
+	myPokedex = make(map[string]pokemonDetails)
+	return &config{
+		Next:         server.APIBase() + "location-area/",
+		apiBase:      server.APIBase(),
+		cache:        cache,
+		client:       client,
+		pokemon:      pokecache.NewTyped(cache, decodePokemon),
+		rng:          rng,
+		out:          io.Discard,
+		settingsPath: filepath.Join(t.TempDir(), "config.json"),
+		catchRate:    defaultCatchRate,
+		commands:     commands.clone(),
+	}, server
+}
+
+// runTest runs a command and returns what it printed.
+func runTest(t *testing.T, c *config, callback func(context.Context, *config, ...string) error, args ...string) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	c.out = &out
+	err := callback(context.Background(), c, args...)
+	return out.String(), err
+}
+
+func TestMapPagination(t *testing.T) {
+	c, _ := newTestConfig(t)
+
+	steps := []struct {
+		callback func(context.Context, *config, ...string) error
+		lines    int
+		first    string
+	}{
+		{callback: commandMap, lines: 20, first: "canalave-city-area"},
+		{callback: commandMap, lines: 5, first: "mt-coronet-1f-route-216"},
+		{callback: commandMapb, lines: 20, first: "canalave-city-area"},
+		{callback: commandMapb, lines: 1, first: "you're on the first page"},
+	}
+
+	for i, step := range steps {
+		out, err := runTest(t, c, step.callback)
+		if err != nil {
+			t.Fatalf("step %d: unexpected error: %v", i, err)
+		}
+		lines := strings.Split(strings.TrimSpace(out), "\n")
+		if len(lines) != step.lines || lines[0] != step.first {
+			t.Errorf("step %d: expected %d lines starting with %s, got %q", i, step.lines, step.first, out)
+		}
+	}
+}
+
+func TestExplore(t *testing.T) {
+	cases := []struct {
+		args    []string
+		version string
+		want    string
+		wantErr string
+	}{
+		{
+			args: []string{"canalave-city-area"},
+			want: "Exploring canalave-city-area...\nFound Pokemon:\n - tentacool\n - tentacruel\n - staryu\n - magikarp\n - gyarados\n",
+		},
+		{
+			args:    []string{"canalave-city-area"},
+			version: "platinum",
+			want:    "Exploring canalave-city-area...\nFound Pokemon:\n - tentacool\n - tentacruel\n - magikarp\n - gyarados\n",
+		},
+		{args: []string{"nowhere"}, wantErr: "location area 'nowhere' not found"},
+	}
+
+	for _, c := range cases {
+		t.Run(strings.Join(c.args, " "), func(t *testing.T) {
+			cfg, _ := newTestConfig(t)
+			cfg.version = c.version
+			out, err := runTest(t, cfg, commmandExplore, c.args...)
+			if c.wantErr != "" {
+				if err == nil || err.Error() != c.wantErr {
+					t.Errorf("expected error %q, got %v", c.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if out != c.want {
+				t.Errorf("expected %q, got %q", c.want, out)
+			}
+		})
+	}
+}
+
+func TestExploreServerError(t *testing.T) {
+	c, server := newTestConfig(t)
+	server.SetFailure(func(*http.Request) int { return http.StatusServiceUnavailable })
+
+	_, err := runTest(t, c, commmandExplore, "canalave-city-area")
+	if err == nil || err.Error() != "error with statuscode: 503 (after 4 attempts)" {
+		t.Errorf("expected 503 error, got %v", err)
+	}
+	if n := len(server.Requests()); n != 4 {
+		t.Errorf("expected 4 requests, got %d", n)
+	}
+}
+
+func TestCatch(t *testing.T) {
+	const (
+		throw   = "Throwing a Pokeball at pikachu...\n"
+		escaped = "pikachu escaped!\n"
+		caught  = "pikachu was caught!\nAdding pikachu to Pokedex\n"
+	)
+	cases := []struct {
+		seed   int64
+		throws int
+		want   string
+	}{
+		{seed: 1, throws: 2, want: throw + escaped + throw + escaped},
+		{seed: 2, throws: 1, want: throw + caught},
+		{seed: 11, throws: 2, want: throw + escaped + throw + caught},
+	}
+
+	for _, c := range cases {
+		t.Run(fmt.Sprintf("seed %d", c.seed), func(t *testing.T) {
+			cfg, _ := newTestConfig(t)
+			cfg.rng = rand.New(rand.NewSource(c.seed))
+			out := ""
+			for i := 0; i < c.throws; i++ {
+				got, err := runTest(t, cfg, commandCatch, "pikachu")
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				out += got
+			}
+			if out != c.want {
+				t.Errorf("expected %q, got %q", c.want, out)
+			}
+			_, ok := myPokedex["pikachu"]
+			if ok != strings.HasSuffix(c.want, caught) {
+				t.Errorf("expected the Pokedex to match the last throw")
+			}
+		})
+	}
+}
+
+func TestCatchNoBaseExperience(t *testing.T) {
+	c, _ := newTestConfig(t)
+	out, err := runTest(t, c, commandCatch, "pikachu-rock-star")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "pikachu-rock-star was caught!"; !strings.Contains(out, want) {
+		t.Errorf("expected %q, got %q", want, out)
+	}
+}
+
+func TestCatchNotFound(t *testing.T) {
+	c, _ := newTestConfig(t)
+	if _, err := runTest(t, c, commandCatch); err == nil || err.Error() != "please provide a Pokemon name" {
+		t.Errorf("expected a missing name error, got %v", err)
+	}
+	if _, err := runTest(t, c, commandCatch, "missingno"); err == nil || err.Error() != "pokemon 'missingno' not found" {
+		t.Errorf("expected not found error, got %v", err)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	c, _ := newTestConfig(t)
+
+	if _, err := runTest(t, c, commandInspect); err == nil || err.Error() != "please provide a Pokemon name" {
+		t.Errorf("expected a missing name error, got %v", err)
+	}
+
+	out, err := runTest(t, c, commandInspect, "pikachu")
+	if err != nil || out != "you have not caught this Pokemon\n" {
+		t.Errorf("expected not caught message, got %q, %v", out, err)
+	}
+
+	pokemon, err := fetchPokemon(context.Background(), c, "pikachu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	myPokedex["pikachu"] = pokemon
+
+	want := "Name: pikachu\nHeight: 4\nWeight: 60\nStats:\n" +
+		" - hp: 35\n - attack: 55\n - defense: 40\n - special-attack: 50\n - special-defense: 50\n - speed: 90\n" +
+		"Types:\n - electric\n"
+	if out, err := runTest(t, c, commandInspect, "pikachu"); err != nil || out != want {
+		t.Errorf("expected %q, got %q, %v", want, out, err)
+	}
+
+	if _, err := runTest(t, c, commandInspect, "pikachu", "--sprit"); err == nil || err.Error() != "unknown flag '--sprit'" {
+		t.Errorf("expected an unknown flag error, got %v", err)
+	}
+
+	c.lang = "de"
+	out, err = runTest(t, c, commandInspect, "pikachu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out, "Name: Pikachu\nEs lagert Elektrizität in seinen Backen.") {
+		t.Errorf("expected German name and flavor text, got %q", out)
+	}
+}
+
+func TestInspectSprite(t *testing.T) {
+	c, server := newTestConfig(t)
+	c.renderer = sprite.ANSI{Mode: sprite.TrueColor}
+	pokemon, err := fetchPokemon(context.Background(), c, "pikachu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	myPokedex["pikachu"] = pokemon
+
+	for i := 0; i < 2; i++ {
+		out, err := runTest(t, c, commandInspect, "pikachu", "--sprite")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// the fixture is red on top and blue below
+		if !strings.Contains(out, "Types:\n - electric\n") || !strings.Contains(out, "38;2;255;0;0;48;2;255;0;0m▀") {
+			t.Errorf("expected details and the rendered sprite, got %q", out)
+		}
+	}
+
+	fetches := 0
+	for _, request := range server.Requests() {
+		if request == "/sprites/pokemon/25.png" {
+			fetches++
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("expected the sprite to be fetched once and then cached, got %d fetches", fetches)
+	}
+}
+
+func TestVersion(t *testing.T) {
+	c, _ := newTestConfig(t)
+
+	steps := []struct {
+		args    []string
+		want    string
+		wantErr string
+	}{
+		{want: "No game version set, showing all versions\n"},
+		{args: []string{"diamond"}, want: "Game version set to diamond (diamond-pearl)\n"},
+		{want: "Game version: diamond (diamond-pearl)\n"},
+		{args: []string{"emerald"}, wantErr: "version 'emerald' not found"},
+		{want: "Game version: diamond (diamond-pearl)\n"},
+		{args: []string{"all"}, want: "Showing all versions\n"},
+		{want: "No game version set, showing all versions\n"},
+	}
+
+	for i, step := range steps {
+		out, err := runTest(t, c, commandVersion, step.args...)
+		if step.wantErr != "" {
+			if err == nil || err.Error() != step.wantErr {
+				t.Errorf("step %d: expected error %q, got %v", i, step.wantErr, err)
+			}
+			continue
+		}
+		if err != nil || out != step.want {
+			t.Errorf("step %d: expected %q, got %q, %v", i, step.want, out, err)
+		}
+	}
+}
+
+func TestStartVersion(t *testing.T) {
+	cases := []struct {
+		name    string
+		status  int
+		want    string
+		wantOut string
+	}{
+		{name: "platinum", want: "platinum"},
+		{name: "gold", wantOut: "Warning: version gold ignored: version 'gold' not found\n"},
+		{name: "platinum", status: http.StatusServiceUnavailable, wantOut: "Warning: version platinum ignored: error with statuscode: 503 (after 4 attempts)\n"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Test case %v", i), func(t *testing.T) {
+			cfg, server := newTestConfig(t)
+			if c.status != 0 {
+				server.SetFailure(func(*http.Request) int { return c.status })
+			}
+			var out bytes.Buffer
+			cfg.out = &out
+			startVersion(context.Background(), cfg, c.name)
+			if cfg.version != c.want || out.String() != c.wantOut {
+				t.Errorf("expected version %q and %q, got %q and %q", c.want, c.wantOut, cfg.version, out.String())
+			}
+		})
+	}
+}
+
+func TestMoves(t *testing.T) {
+	cases := []struct {
+		version string
+		want    string
+	}{
+		{want: "Moves for pikachu:\n - thunder-shock\n - thunderbolt\n"},
+		{version: "diamond", want: "Moves for pikachu in diamond-pearl:\n - thunder-shock (level 1)\n - thunderbolt (machine)\n"},
+		{version: "platinum", want: "Moves for pikachu in platinum:\nNo moves in this version\n"},
+	}
+
+	for _, c := range cases {
+		t.Run("version "+c.version, func(t *testing.T) {
+			cfg, _ := newTestConfig(t)
+			if c.version != "" {
+				if err := setVersion(context.Background(), cfg, c.version); err != nil {
+					t.Fatal(err)
+				}
+			}
+			out, err := runTest(t, cfg, commandMoves, "pikachu")
+			if err != nil || out != c.want {
+				t.Errorf("expected %q, got %q, %v", c.want, out, err)
+			}
+		})
+	}
+}
+
+func TestWhere(t *testing.T) {
+	cases := []struct {
+		version string
+		want    string
+	}{
+		{want: "pikachu can be found in:\n - trophy-garden-area (platinum)\n"},
+		{version: "platinum", want: "pikachu can be found in:\n - trophy-garden-area (10%)\n"},
+		{version: "diamond", want: "pikachu can be found in:\nNo wild encounters\n"},
+	}
+
+	for _, c := range cases {
+		t.Run("version "+c.version, func(t *testing.T) {
+			cfg, _ := newTestConfig(t)
+			cfg.version = c.version
+			out, err := runTest(t, cfg, commandWhere, "pikachu")
+			if err != nil || out != c.want {
+				t.Errorf("expected %q, got %q, %v", c.want, out, err)
+			}
+		})
+	}
+
+	c, _ := newTestConfig(t)
+	if _, err := runTest(t, c, commandWhere, "missingno"); err == nil || err.Error() != "pokemon 'missingno' not found" {
+		t.Errorf("expected not found error, got %v", err)
+	}
+}
+
+func TestLocalize(t *testing.T) {
+	c, server := newTestConfig(t)
+	c.lang = "de"
+
+	names, err := localize(context.Background(), c, []string{"pikachu", "missingno"}, pokemonName)
+	if err != nil || strings.Join(names, " ") != "Pikachu missingno" {
+		t.Errorf("expected Pikachu and a fallback for missingno, got %q, %v", names, err)
+	}
+
+	// a cancelled lookup stops the command instead of falling back, and
+	// leaves the page where it was
+	server.SetLatency(time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	next := c.Next
+	c.cache.Add(next, []byte(`{"next": "later", "results": [{"name": "canalave-city-area"}, {"name": "eterna-city-area"}]}`))
+	if err := commandMap(ctx, c); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline to stop map, got %v", err)
+	}
+	if c.Next != next {
+		t.Errorf("expected the page not to move, got %s", c.Next)
+	}
+}
+
+func TestCry(t *testing.T) {
+	cases := []struct {
+		args    []string
+		file    string
+		want    string
+		wantErr string
+	}{
+		{args: []string{"pikachu"}, file: "pikachu.ogg", want: "OggS latest pikachu cry"},
+		{args: []string{"--legacy", "pikachu"}, file: "pikachu-legacy.ogg", want: "OggS legacy pikachu cry"},
+		{args: []string{"pikachu", "-o", "sub/pika.ogg"}, file: "sub/pika.ogg", want: "OggS latest pikachu cry"},
+		{args: []string{"--loud", "pikachu"}, wantErr: "unknown flag '--loud'"},
+		{args: []string{"pikachu", "raichu"}, wantErr: "please provide one Pokemon name, not 'pikachu' and 'raichu'"},
+		{args: []string{"pikachu", "-o"}, wantErr: "-o needs a file name"},
+		{args: []string{"--all", "pikachu"}, wantErr: "--all saves every caught Pokemon, leave out 'pikachu'"},
+		{args: []string{"missingno"}, wantErr: "pokemon 'missingno' not found"},
+	}
+
+	for _, c := range cases {
+		t.Run(strings.Join(c.args, " "), func(t *testing.T) {
+			cfg, _ := newTestConfig(t)
+			cfg.saveDir = t.TempDir()
+			args := slices.Clone(c.args)
+			for i, arg := range args {
+				if i > 0 && args[i-1] == "-o" {
+					args[i] = filepath.Join(cfg.saveDir, arg)
+				}
+			}
+
+			out, err := runTest(t, cfg, commandCry, args...)
+			if c.wantErr != "" {
+				if err == nil || err.Error() != c.wantErr {
+					t.Errorf("expected error %q, got %v", c.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			path := filepath.Join(cfg.saveDir, c.file)
+			if want := "Saved pikachu cry to " + path + "\n"; out != want {
+				t.Errorf("expected %q, got %q", want, out)
+			}
+			if data, err := os.ReadFile(path); err != nil || string(data) != c.want {
+				t.Errorf("expected %s to hold %q, got %q, %v", c.file, c.want, data, err)
+			}
+		})
+	}
+}
+
+func TestCryAll(t *testing.T) {
+	c, _ := newTestConfig(t)
+	c.saveDir = t.TempDir()
+
+	out, err := runTest(t, c, commandCry, "--all")
+	if err != nil || out != "You have not caught any Pokemon\n" {
+		t.Errorf("expected nothing to save, got %q, %v", out, err)
+	}
+
+	// the file is named after the Pokemon, not what the user typed
+	pokemon, err := fetchPokemon(context.Background(), c, "pikachu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	myPokedex["PIKACHU"] = pokemon
+
+	if _, err := runTest(t, c, commandCry, "--all"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(c.saveDir, "pikachu.ogg")); err != nil {
+		t.Errorf("expected pikachu.ogg to be saved: %v", err)
+	}
+}
+
+func TestSync(t *testing.T) {
+	c, server := newTestConfig(t)
+	c.dump = t.TempDir()
+	c.client.Limiter = pokeapi.NewLimiter(100, 1)
+	c.client.OnWait = func(time.Duration) {
+		t.Errorf("expected sync to report rate limit waits once, not one by one")
+	}
+
+	out, err := runTest(t, c, commandSync, "pokemon")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out, "(rate limited ") {
+		t.Errorf("expected a rate limit summary, got %q", out)
+	}
+	if c.client.OnWait == nil {
+		t.Errorf("expected OnWait to be restored")
+	}
+
+	dump, err := pokeapi.OpenDump(c.dump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dump.Close()
+	urls := []string{
+		server.APIBase() + "pokemon/pikachu",
+		server.APIBase() + "pokemon/25/",
+		server.APIBase() + "pokemon/25/encounters",
+	}
+	for _, url := range urls {
+		if _, err := dump.Get(url); err != nil {
+			t.Errorf("expected %s in the dump: %v", url, err)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// TestRunGolden feeds each testdata/*.txt script to the REPL and compares
+// the transcript with the matching .golden file. Run with -update after an
+// intended change to the output.
+func TestRunGolden(t *testing.T) {
+	scripts, err := filepath.Glob(filepath.Join("testdata", "*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scripts) == 0 {
+		t.Fatal("no scripts in testdata")
+	}
+
+	for _, script := range scripts {
+		name := strings.TrimSuffix(filepath.Base(script), ".txt")
+		t.Run(name, func(t *testing.T) {
+			input, err := os.ReadFile(script)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c, _ := newTestConfig(t)
+			var out bytes.Buffer
+			if err := c.Run(context.Background(), bytes.NewReader(input), &out); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			// the config file is in a temporary directory that changes
+			// from run to run
+			got := strings.ReplaceAll(out.String(), filepath.Dir(c.settingsPath), "$CONFIG_DIR")
+
+			golden := strings.TrimSuffix(script, ".txt") + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("transcript differs from %s:\n%s", golden, got)
+			}
+		})
+	}
+}
+
+func TestRunCancelled(t *testing.T) {
+	c, _ := newTestConfig(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	in, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if err := c.Run(ctx, in, &bytes.Buffer{}); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestRunInterrupt(t *testing.T) {
+	const (
+		prompt = "Pokedex > "
+		hint   = "\n(press Ctrl-C again or type exit to quit)\n"
+		bye    = "\nClosing the Pokedex... Goodbye!\n"
+	)
+	cases := []struct {
+		steps []string
+		want  string
+	}{
+		{
+			steps: []string{"^C", "^C"},
+			want:  prompt + hint + prompt + bye,
+		},
+		{
+			// a line in between starts over
+			steps: []string{"^C", "", "^C", "exit"},
+			want:  prompt + hint + prompt + prompt + hint + prompt + "Closing the Pokedex... Goodbye!\n",
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Test case %v", i), func(t *testing.T) {
+			cfg, _ := newTestConfig(t)
+			interrupts := make(chan os.Signal)
+			cfg.interrupts = interrupts
+
+			in, w, err := os.Pipe()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer w.Close()
+			outR, outW := io.Pipe()
+			result := make(chan error, 1)
+			go func() {
+				result <- cfg.Run(context.Background(), in, outW)
+				outW.Close()
+			}()
+
+			// reading up to each prompt keeps the steps in order
+			out := bufio.NewReader(outR)
+			var got strings.Builder
+			readPrompt := func() {
+				start := got.Len()
+				for got.Len() == start || !strings.HasSuffix(got.String(), prompt) {
+					b, err := out.ReadByte()
+					if err != nil {
+						t.Fatalf("expected a prompt, got %q: %v", got.String(), err)
+					}
+					got.WriteByte(b)
+				}
+			}
+			readPrompt()
+			for i, step := range c.steps {
+				if step == "^C" {
+					interrupts <- os.Interrupt
+				} else if _, err := w.WriteString(step + "\n"); err != nil {
+					t.Fatal(err)
+				}
+				if i < len(c.steps)-1 {
+					readPrompt()
+				}
+			}
+			rest, _ := io.ReadAll(out)
+			got.Write(rest)
+			if err := <-result; err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.String() != c.want {
+				t.Errorf("expected %q, got %q", c.want, got.String())
+			}
+		})
+	}
+}
+
+// endlessReader never runs out of lines.
+type endlessReader struct{}
+
+func (endlessReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = '\n'
+	}
+	return len(p), nil
+}
+
+func TestReadLinesStops(t *testing.T) {
+	done := make(chan struct{})
+	lines := readLines(endlessReader{}, done)
+	<-lines
+	close(done)
+	for range lines {
+	}
+}
@@ -0,0 +1,417 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Defaults for the settings that have one, shared with the flags in main.
+const (
+	defaultCacheTTL = 5 * time.Minute
+	defaultCacheMB  = 64
+	defaultTheme    = "none"
+	defaultFormat   = "text"
+	defaultPrompt   = "Pokedex >"
+	// defaultCatchRate catches a Pokemon when a roll below its base
+	// experience comes up under 20, so rarer Pokemon escape more often.
+	defaultCatchRate = 20
+)
+
+// settings are what the Pokedex remembers between sessions. They live in
+// a JSON file in the user's config directory. Empty fields fall back to
+// the defaults, and flags override them for one session.
+type settings struct {
+	APIBase   string              `json:"api-base,omitempty"`
+	CacheTTL  string              `json:"cache-ttl,omitempty"`
+	CacheMB   int                 `json:"cache-mb,omitempty"`
+	SaveDir   string              `json:"save-dir,omitempty"`
+	Version   string              `json:"version,omitempty"`
+	Lang      string              `json:"lang,omitempty"`
+	Theme     string              `json:"theme,omitempty"`
+	Format    string              `json:"format,omitempty"`
+	Prompt    string              `json:"prompt,omitempty"`
+	Seed      *int64              `json:"seed,omitempty"`
+	CatchRate int                 `json:"catch-rate,omitempty"`
+	Aliases   map[string]string   `json:"aliases,omitempty"`
+	Macros    map[string][]string `json:"macros,omitempty"`
+}
+
+// settingKey is one setting that config can show and set. Its name is
+// also its name in the file and the name of the flag that overrides it.
+type settingKey struct {
+	name  string
+	def   string
+	field func(*settings) any
+	check func(string) error
+	// apply changes the running session. Settings without it take effect
+	// the next time the Pokedex starts.
+	apply func(context.Context, *config, string) error
+}
+
+var settingKeys = []settingKey{
+	{
+		name:  "api-base",
+		def:   defaultAPIBase,
+		field: func(s *settings) any { return &s.APIBase },
+		check: checkAPIBase,
+	},
+	{
+		name:  "cache-ttl",
+		def:   defaultCacheTTL.String(),
+		field: func(s *settings) any { return &s.CacheTTL },
+		check: checkCacheTTL,
+	},
+	{
+		name:  "cache-mb",
+		def:   strconv.Itoa(defaultCacheMB),
+		field: func(s *settings) any { return &s.CacheMB },
+		check: checkCacheMB,
+	},
+	{
+		name:  "save-dir",
+		field: func(s *settings) any { return &s.SaveDir },
+		apply: func(ctx context.Context, c *config, value string) error {
+			c.saveDir = value
+			return nil
+		},
+	},
+	{
+		name:  "version",
+		field: func(s *settings) any { return &s.Version },
+		apply: func(ctx context.Context, c *config, value string) error {
+			if value == "" {
+				c.version, c.versionGroup = "", ""
+				return nil
+			}
+			return setVersion(ctx, c, value)
+		},
+	},
+	{
+		name:  "lang",
+		field: func(s *settings) any { return &s.Lang },
+		apply: func(ctx context.Context, c *config, value string) error {
+			c.lang = value
+			return nil
+		},
+	},
+	{
+		name:  "theme",
+		def:   defaultTheme,
+		field: func(s *settings) any { return &s.Theme },
+		check: checkTheme,
+		apply: func(ctx context.Context, c *config, value string) error {
+			c.theme = themes[value]
+			return nil
+		},
+	},
+	{
+		name:  "format",
+		def:   defaultFormat,
+		field: func(s *settings) any { return &s.Format },
+		check: checkFormat,
+		apply: func(ctx context.Context, c *config, value string) error {
+			c.format = value
+			return nil
+		},
+	},
+	{
+		name:  "prompt",
+		def:   defaultPrompt,
+		field: func(s *settings) any { return &s.Prompt },
+		apply: func(ctx context.Context, c *config, value string) error {
+			c.prompt = value
+			return nil
+		},
+	},
+	{
+		name:  "seed",
+		field: func(s *settings) any { return &s.Seed },
+		check: checkSeed,
+	},
+	{
+		name:  "catch-rate",
+		def:   strconv.Itoa(defaultCatchRate),
+		field: func(s *settings) any { return &s.CatchRate },
+		check: checkCatchRate,
+		apply: func(ctx context.Context, c *config, value string) error {
+			c.catchRate, _ = strconv.Atoi(value)
+			return nil
+		},
+	},
+}
+
+func lookupSetting(name string) (settingKey, bool) {
+	for _, key := range settingKeys {
+		if key.name == name {
+			return key, true
+		}
+	}
+	return settingKey{}, false
+}
+
+// get returns the setting's value in s, or "" if it isn't set.
+func (key settingKey) get(s *settings) string {
+	switch field := key.field(s).(type) {
+	case *string:
+		return *field
+	case *int:
+		if *field != 0 {
+			return strconv.Itoa(*field)
+		}
+	case **int64:
+		if *field != nil {
+			return strconv.FormatInt(**field, 10)
+		}
+	}
+	return ""
+}
+
+// set checks value and stores it in s. An empty value unsets the setting.
+func (key settingKey) set(s *settings, value string) error {
+	if value != "" && key.check != nil {
+		if err := key.check(value); err != nil {
+			return err
+		}
+	}
+	switch field := key.field(s).(type) {
+	case *string:
+		*field = value
+	case *int:
+		*field, _ = strconv.Atoi(value)
+	case **int64:
+		*field = nil
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			*field = &n
+		}
+	}
+	return nil
+}
+
+func checkAPIBase(value string) error {
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("api-base must be an http or https URL")
+	}
+	return nil
+}
+
+func checkCacheTTL(value string) error {
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl <= 0 {
+		return fmt.Errorf("cache-ttl must be a duration such as 5m or 1h")
+	}
+	return nil
+}
+
+func checkCacheMB(value string) error {
+	if n, err := strconv.Atoi(value); err != nil || n <= 0 {
+		return fmt.Errorf("cache-mb must be a positive number")
+	}
+	return nil
+}
+
+func checkSeed(value string) error {
+	if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+		return fmt.Errorf("seed must be a number")
+	}
+	return nil
+}
+
+func checkCatchRate(value string) error {
+	if n, err := strconv.Atoi(value); err != nil || n <= 0 {
+		return fmt.Errorf("catch-rate must be a positive number")
+	}
+	return nil
+}
+
+// seedValue is the -seed flag. Unlike an int64 flag it knows whether it
+// was given, so 0 can be replayed like any other seed.
+type seedValue struct {
+	seed int64
+	set  bool
+}
+
+func (v *seedValue) String() string {
+	if !v.set {
+		return ""
+	}
+	return strconv.FormatInt(v.seed, 10)
+}
+
+func (v *seedValue) Set(value string) error {
+	if err := checkSeed(value); err != nil {
+		return err
+	}
+	v.seed, _ = strconv.ParseInt(value, 10, 64)
+	v.set = true
+	return nil
+}
+
+// givenSettings returns the settings given as flags on the command line,
+// with their values.
+func givenSettings(fs *flag.FlagSet) map[string]string {
+	given := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		if _, ok := lookupSetting(f.Name); ok {
+			given[f.Name] = f.Value.String()
+		}
+	})
+	return given
+}
+
+// applySettings sets every flag in fs that wasn't given on the command
+// line to its value in s, so flags override the file and the file
+// overrides the defaults. It then checks the value of every flag.
+func applySettings(fs *flag.FlagSet, s settings) error {
+	given := givenSettings(fs)
+
+	for _, key := range settingKeys {
+		if _, ok := given[key.name]; !ok && key.get(&s) != "" {
+			value := key.get(&s)
+			if key.check != nil {
+				if err := key.check(value); err != nil {
+					return fmt.Errorf("error in config: %v", err)
+				}
+			}
+			if err := fs.Set(key.name, value); err != nil {
+				return fmt.Errorf("error in config: %v", err)
+			}
+		}
+		if value := fs.Lookup(key.name).Value.String(); value != "" && key.check != nil {
+			if err := key.check(value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func commandConfig(ctx context.Context, c *config, name ...string) error {
+	if len(name) == 0 || name[0] == "show" {
+		path := c.settingsPath
+		if path == "" {
+			path = "none, settings are not saved"
+		}
+		fmt.Fprintf(c.out, "Config file: %s\n", path)
+		for _, key := range settingKeys {
+			if value, source := c.setting(key); value != "" {
+				fmt.Fprintf(c.out, " - %s = %s (%s)\n", key.name, value, source)
+			} else {
+				fmt.Fprintf(c.out, " - %s is not set\n", key.name)
+			}
+		}
+		return nil
+	}
+
+	if name[0] != "set" || len(name) < 2 {
+		return fmt.Errorf("usage: config [show] | config set <key> [value]")
+	}
+	key, ok := lookupSetting(name[1])
+	if !ok {
+		return fmt.Errorf("unknown setting '%s'", name[1])
+	}
+	value := strings.Join(name[2:], " ")
+
+	next := c.settings
+	if err := key.set(&next, value); err != nil {
+		return err
+	}
+	if key.apply != nil {
+		current := value
+		if current == "" {
+			current = key.def
+		}
+		if err := key.apply(ctx, c, current); err != nil {
+			return err
+		}
+	}
+	c.settings = next
+	if key.apply != nil {
+		// the session uses the new value now, not the flag's
+		delete(c.flags, key.name)
+	}
+
+	if value == "" {
+		fmt.Fprintf(c.out, "Unset %s\n", key.name)
+	} else {
+		fmt.Fprintf(c.out, "Set %s = %s\n", key.name, value)
+	}
+	if key.apply == nil {
+		fmt.Fprintln(c.out, "This takes effect the next time the Pokedex starts")
+	}
+	return saveSettings(c)
+}
+
+// setting returns the value of key in effect and where it comes from: a
+// flag, the config file or the default.
+func (c *config) setting(key settingKey) (value, source string) {
+	if value, ok := c.flags[key.name]; ok {
+		return value, "flag"
+	}
+	if value := key.get(&c.settings); value != "" {
+		return value, "config file"
+	}
+	return key.def, "default"
+}
+
+// defaultSettingsPath returns where settings are kept, or "" if the system
+// has no config directory, in which case they are not saved.
+func defaultSettingsPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "pokedex", "config.json")
+}
+
+// loadSettings reads the settings file at path. A missing file gives empty
+// settings.
+func loadSettings(path string) (settings, error) {
+	s := settings{}
+	if path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return s, fmt.Errorf("error reading config: %v", err)
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, fmt.Errorf("error parsing %s: %v", path, err)
+	}
+	return s, nil
+}
+
+// saveSettings writes c.settings to its file, replacing the old one only
+// once the new one is complete.
+func saveSettings(c *config) error {
+	if c.settingsPath == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(c.settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.settingsPath), 0755); err != nil {
+		return fmt.Errorf("error creating directory: %v", err)
+	}
+	tmp := c.settingsPath + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("error writing config: %v", err)
+	}
+	if err := os.Rename(tmp, c.settingsPath); err != nil {
+		return fmt.Errorf("error writing config: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestApplySettings(t *testing.T) {
+	cases := []struct {
+		file    settings
+		args    []string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			want: map[string]string{"theme": "none", "cache-ttl": "5m0s", "seed": ""},
+		},
+		{
+			file: settings{Seed: seed(0)},
+			want: map[string]string{"seed": "0"},
+		},
+		{
+			file: settings{Theme: "dark", CacheTTL: "1h", Seed: seed(7)},
+			want: map[string]string{"theme": "dark", "cache-ttl": "1h0m0s", "seed": "7"},
+		},
+		{
+			file: settings{Theme: "dark", CacheTTL: "1h", Seed: seed(7)},
+			args: []string{"-theme", "light", "-seed", "9"},
+			want: map[string]string{"theme": "light", "cache-ttl": "1h0m0s", "seed": "9"},
+		},
+		{
+			file:    settings{CacheTTL: "soon"},
+			wantErr: "error in config: cache-ttl must be a duration such as 5m or 1h",
+		},
+		{
+			args:    []string{"-format", "yaml"},
+			wantErr: "unknown format 'yaml', use text or json",
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Test case %v", i), func(t *testing.T) {
+			fs := flag.NewFlagSet("pokedex", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			fs.String("api-base", defaultAPIBase, "")
+			fs.Duration("cache-ttl", defaultCacheTTL, "")
+			fs.Int("cache-mb", defaultCacheMB, "")
+			fs.String("save-dir", "", "")
+			fs.String("version", "", "")
+			fs.String("lang", "", "")
+			fs.String("theme", defaultTheme, "")
+			fs.String("format", defaultFormat, "")
+			fs.String("prompt", defaultPrompt, "")
+			fs.Var(&seedValue{}, "seed", "")
+			fs.Int("catch-rate", defaultCatchRate, "")
+			if err := fs.Parse(c.args); err != nil {
+				t.Fatal(err)
+			}
+
+			err := applySettings(fs, c.file)
+			if c.wantErr != "" {
+				if err == nil || err.Error() != c.wantErr {
+					t.Errorf("expected error %q, got %v", c.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for name, want := range c.want {
+				if got := fs.Lookup(name).Value.String(); got != want {
+					t.Errorf("expected -%s %q, got %q", name, want, got)
+				}
+			}
+		})
+	}
+}
+
+func seed(n int64) *int64 {
+	return &n
+}
+
+func TestConfigSet(t *testing.T) {
+	c, _ := newTestConfig(t)
+
+	steps := [][]string{
+		{"set", "lang", "de"},
+		{"set", "prompt", "my", "dex>"},
+		{"set", "cache-mb", "128"},
+		{"set", "seed", "0"},
+		{"set", "catch-rate", "50"},
+	}
+	for _, step := range steps {
+		if _, err := runTest(t, c, commandConfig, step...); err != nil {
+			t.Fatalf("config %v: unexpected error: %v", step, err)
+		}
+	}
+	if c.lang != "de" || c.prompt != "my dex>" || c.catchRate != 50 {
+		t.Errorf("expected lang, prompt and catch-rate to apply now, got %q, %q and %d", c.lang, c.prompt, c.catchRate)
+	}
+
+	if _, err := runTest(t, c, commandConfig, "set", "cache-mb", "-1"); err == nil {
+		t.Errorf("expected a negative cache size to be refused")
+	}
+
+	s, err := loadSettings(c.settingsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Lang != "de" || s.Prompt != "my dex>" || s.CacheMB != 128 || s.Seed == nil || *s.Seed != 0 {
+		t.Errorf("expected settings to be saved, got %+v", s)
+	}
+
+	if _, err := runTest(t, c, commandConfig, "set", "lang"); err != nil {
+		t.Fatal(err)
+	}
+	if c.lang != "" || c.settings.Lang != "" {
+		t.Errorf("expected lang to be unset, got %q", c.lang)
+	}
+}
+
+func TestConfigShow(t *testing.T) {
+	c, _ := newTestConfig(t)
+	c.flags = map[string]string{"theme": "dark", "cache-ttl": "1m0s"}
+	c.settings = settings{Theme: "light", CacheTTL: "1h", Lang: "de"}
+
+	steps := []struct {
+		args []string
+		want []string
+	}{
+		{
+			args: []string{"show"},
+			want: []string{" - theme = dark (flag)", " - cache-ttl = 1m0s (flag)", " - lang = de (config file)", " - format = text (default)", " - seed is not set"},
+		},
+		{
+			args: []string{"set", "theme", "none"},
+		},
+		{
+			args: []string{"set", "cache-ttl", "2h"},
+		},
+		{
+			// theme applies now, cache-ttl only after a restart
+			args: []string{"show"},
+			want: []string{" - theme = none (config file)", " - cache-ttl = 1m0s (flag)"},
+		},
+	}
+	for _, step := range steps {
+		out, err := runTest(t, c, commandConfig, step.args...)
+		if err != nil {
+			t.Fatalf("config %v: unexpected error: %v", step.args, err)
+		}
+		for _, want := range step.want {
+			if !strings.Contains(out, want+"\n") {
+				t.Errorf("config %v: expected %q in %q", step.args, want, out)
+			}
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+const spriteWidth = 48
+
+func spriteString(v any) string {
+	s, _ := v.(string)
+	return s
+}
+
+// spriteURL picks the front sprite matching the active game version,
+// falling back to the default sprite when that generation has none.
+func spriteURL(p pokemonDetails, version, versionGroup string, shiny bool) string {
+	v := p.Sprites.Versions
+	var front, frontShiny any
+
+	switch versionGroup {
+	case "red-blue":
+		front = v.GenerationI.RedBlue.FrontDefault
+	case "yellow":
+		front = v.GenerationI.Yellow.FrontDefault
+	case "gold-silver":
+		front, frontShiny = v.GenerationIi.Gold.FrontDefault, v.GenerationIi.Gold.FrontShiny
+		if version == "silver" {
+			front, frontShiny = v.GenerationIi.Silver.FrontDefault, v.GenerationIi.Silver.FrontShiny
+		}
+	case "crystal":
+		front, frontShiny = v.GenerationIi.Crystal.FrontDefault, v.GenerationIi.Crystal.FrontShiny
+	case "ruby-sapphire":
+		front, frontShiny = v.GenerationIii.RubySapphire.FrontDefault, v.GenerationIii.RubySapphire.FrontShiny
+	case "emerald":
+		front, frontShiny = v.GenerationIii.Emerald.FrontDefault, v.GenerationIii.Emerald.FrontShiny
+	case "firered-leafgreen":
+		front, frontShiny = v.GenerationIii.FireredLeafgreen.FrontDefault, v.GenerationIii.FireredLeafgreen.FrontShiny
+	case "diamond-pearl":
+		front, frontShiny = v.GenerationIv.DiamondPearl.FrontDefault, v.GenerationIv.DiamondPearl.FrontShiny
+	case "platinum":
+		front, frontShiny = v.GenerationIv.Platinum.FrontDefault, v.GenerationIv.Platinum.FrontShiny
+	case "heartgold-soulsilver":
+		front, frontShiny = v.GenerationIv.HeartgoldSoulsilver.FrontDefault, v.GenerationIv.HeartgoldSoulsilver.FrontShiny
+	case "black-white", "black-2-white-2":
+		front, frontShiny = v.GenerationV.BlackWhite.FrontDefault, v.GenerationV.BlackWhite.FrontShiny
+	case "x-y":
+		front, frontShiny = v.GenerationVi.XY.FrontDefault, v.GenerationVi.XY.FrontShiny
+	case "omega-ruby-alpha-sapphire":
+		front, frontShiny = v.GenerationVi.OmegarubyAlphasapphire.FrontDefault, v.GenerationVi.OmegarubyAlphasapphire.FrontShiny
+	case "ultra-sun-ultra-moon":
+		front, frontShiny = v.GenerationVii.UltraSunUltraMoon.FrontDefault, v.GenerationVii.UltraSunUltraMoon.FrontShiny
+	}
+
+	if shiny {
+		if url := spriteString(frontShiny); url != "" {
+			return url
+		}
+		return p.Sprites.FrontShiny
+	}
+	if url := spriteString(front); url != "" {
+		return url
+	}
+	return p.Sprites.FrontDefault
+}
+
+func showSprite(ctx context.Context, c *config, p pokemonDetails, shiny bool) error {
+	url := spriteURL(p, c.version, c.versionGroup, shiny)
+	if url == "" {
+		return fmt.Errorf("no sprite available for %s", p.Name)
+	}
+
+	data, err := fetch(ctx, c, url)
+	if err != nil {
+		return err
+	}
+
+	return c.renderer.Render(c.out, data)
+}
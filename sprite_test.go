@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestSpriteURL(t *testing.T) {
+	const data = `{
+		"sprites": {
+			"front_default": "default.png",
+			"front_shiny": "shiny.png",
+			"versions": {
+				"generation-i": {
+					"red-blue": {"front_default": "red-blue.png"}
+				},
+				"generation-ii": {
+					"gold": {"front_default": "gold.png", "front_shiny": "gold-shiny.png"},
+					"silver": {"front_default": "silver.png", "front_shiny": "silver-shiny.png"}
+				},
+				"generation-iv": {
+					"platinum": {"front_default": "platinum.png", "front_shiny": "platinum-shiny.png"},
+					"diamond-pearl": {"front_default": null, "front_shiny": null}
+				}
+			}
+		}
+	}`
+	var pokemon pokemonDetails
+	if err := json.Unmarshal([]byte(data), &pokemon); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		version      string
+		versionGroup string
+		shiny        bool
+		want         string
+	}{
+		{want: "default.png"},
+		{shiny: true, want: "shiny.png"},
+		{version: "platinum", versionGroup: "platinum", want: "platinum.png"},
+		{version: "platinum", versionGroup: "platinum", shiny: true, want: "platinum-shiny.png"},
+		{version: "gold", versionGroup: "gold-silver", want: "gold.png"},
+		{version: "silver", versionGroup: "gold-silver", shiny: true, want: "silver-shiny.png"},
+		// generation I has no shiny sprites
+		{version: "red", versionGroup: "red-blue", want: "red-blue.png"},
+		{version: "red", versionGroup: "red-blue", shiny: true, want: "shiny.png"},
+		{version: "diamond", versionGroup: "diamond-pearl", want: "default.png"},
+		{version: "sword", versionGroup: "sword-shield", want: "default.png"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Test case %v", i), func(t *testing.T) {
+			if got := spriteURL(pokemon, c.version, c.versionGroup, c.shiny); got != c.want {
+				t.Errorf("expected %q, got %q", c.want, got)
+			}
+		})
+	}
+}
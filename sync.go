@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Lusbox/Pokedex/internal/pokeapi"
+)
+
+// syncResources are the PokeAPI resources the REPL reads, in the order
+// sync downloads them.
+var syncResources = []string{"location-area", "pokemon", "pokemon-species", "type", "move", "version"}
+
+func commandSync(ctx context.Context, c *config, name ...string) error {
+	if c.client.Dump != nil {
+		return fmt.Errorf("sync needs the network, restart without -offline")
+	}
+	for _, resource := range name {
+		if !slices.Contains(syncResources, resource) {
+			return fmt.Errorf("unknown resource '%s', choose from %s", resource, strings.Join(syncResources, ", "))
+		}
+	}
+	resources := syncResources
+	if len(name) > 0 {
+		resources = name
+	}
+
+	if info, err := os.Stat(c.dump); err == nil && !info.IsDir() {
+		return fmt.Errorf("%s is an archive, sync needs a directory", c.dump)
+	}
+	if err := os.MkdirAll(c.dump, 0755); err != nil {
+		return fmt.Errorf("error creating directory: %v", err)
+	}
+	existing, err := pokeapi.OpenDump(c.dump)
+	if err != nil {
+		return err
+	}
+	defer existing.Close()
+
+	// a line per rate limit wait would bury the progress, so count them
+	// and report once at the end
+	waits, waited := 0, time.Duration(0)
+	if onWait := c.client.OnWait; onWait != nil {
+		c.client.OnWait = func(wait time.Duration) {
+			waits++
+			waited += wait
+		}
+		defer func() { c.client.OnWait = onWait }()
+	}
+
+	for _, resource := range resources {
+		if err := syncResource(ctx, c, existing, resource); err != nil {
+			return err
+		}
+	}
+	if waits > 0 {
+		fmt.Fprintf(c.out, "(rate limited %d times, waited %v in total)\n", waits, waited.Round(time.Millisecond))
+	}
+	fmt.Fprintf(c.out, "Dump saved to %s\n", c.dump)
+	return nil
+}
+
+// syncResource downloads the full list of resource and every entry in it
+// by name, the way the REPL asks for them. Each entry is also saved under
+// the url the list gives for it, since other resources link to it that
+// way. Entries already in the dump are skipped, so an interrupted sync
+// picks up where it stopped.
+func syncResource(ctx context.Context, c *config, existing *pokeapi.Dump, resource string) error {
+	listURL := c.apiBase + resource + "/?limit=100000"
+	body, err := fetch(ctx, c, listURL)
+	if err != nil {
+		return err
+	}
+	var list maplocations
+	if err := json.Unmarshal(body, &list); err != nil {
+		return fmt.Errorf("error parsing response: %v", err)
+	}
+	if err := pokeapi.WriteDump(c.dump, listURL, body); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.out, "Syncing %d %s resources...\n", len(list.Results), resource)
+	failed := 0
+	for i, item := range list.Results {
+		body, err := syncURL(ctx, c, existing, c.apiBase+resource+"/"+item.Name)
+		if err == nil && item.URL != "" {
+			err = saveDump(c, existing, item.URL, body)
+		}
+		if err == nil && resource == "pokemon" {
+			var pokemon pokemonDetails
+			if err = json.Unmarshal(body, &pokemon); err == nil {
+				_, err = syncURL(ctx, c, existing, pokemon.LocationAreaEncounters)
+			}
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			fmt.Fprintf(c.out, "Error: %s: %v\n", item.Name, err)
+			failed++
+		}
+		if (i+1)%100 == 0 {
+			fmt.Fprintf(c.out, " %d/%d\n", i+1, len(list.Results))
+		}
+	}
+	if failed > 0 {
+		fmt.Fprintf(c.out, "%d of %d %s resources could not be saved\n", failed, len(list.Results), resource)
+	}
+	return nil
+}
+
+func syncURL(ctx context.Context, c *config, existing *pokeapi.Dump, url string) ([]byte, error) {
+	if body, err := existing.Get(url); err == nil {
+		return body, nil
+	}
+	body, err := fetch(ctx, c, url)
+	if err != nil {
+		return nil, err
+	}
+	return body, pokeapi.WriteDump(c.dump, url, body)
+}
+
+// saveDump stores body under url unless the dump already has it.
+func saveDump(c *config, existing *pokeapi.Dump, url string, body []byte) error {
+	if _, err := existing.Get(url); err == nil {
+		return nil
+	}
+	return pokeapi.WriteDump(c.dump, url, body)
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// theme holds the ANSI SGR codes used to color the REPL. Empty codes leave
+// text as it is, so the zero theme prints no escape sequences.
+type theme struct {
+	prompt string
+	err    string
+}
+
+var themes = map[string]theme{
+	"none":  {},
+	"dark":  {prompt: "1;36", err: "1;31"},
+	"light": {prompt: "1;34", err: "31"},
+}
+
+func (t theme) paint(code, text string) string {
+	if code == "" {
+		return text
+	}
+	return "\033[" + code + "m" + text + "\033[0m"
+}
+
+func checkTheme(name string) error {
+	if _, ok := themes[name]; !ok {
+		return fmt.Errorf("unknown theme '%s', use %s", name, strings.Join(sortedKeys(themes), ", "))
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Lusbox/Pokedex/internal/pokeapi"
+)
+
+// startupVersionTimeout bounds the version lookup at startup, so a slow
+// PokeAPI doesn't hold up the prompt.
+const startupVersionTimeout = 5 * time.Second
+
+type gameVersion struct {
+	ID           int    `json:"id"`
+	Name         string `json:"name"`
+	VersionGroup struct {
+		Name string `json:"name"`
+		URL  string `json:"url"`
+	} `json:"version_group"`
+}
+
+type pokemonEncounters []struct {
+	LocationArea struct {
+		Name string `json:"name"`
+		URL  string `json:"url"`
+	} `json:"location_area"`
+	VersionDetails []struct {
+		MaxChance int `json:"max_chance"`
+		Version   struct {
+			Name string `json:"name"`
+			URL  string `json:"url"`
+		} `json:"version"`
+	} `json:"version_details"`
+}
+
+func setVersion(ctx context.Context, c *config, name string) error {
+	body, err := fetch(ctx, c, c.apiBase+"version/"+name)
+	if errors.Is(err, pokeapi.ErrNotFound) {
+		return fmt.Errorf("version '%s' not found", name)
+	}
+	if err != nil {
+		return err
+	}
+
+	var version gameVersion
+	if err := json.Unmarshal(body, &version); err != nil {
+		return fmt.Errorf("error parsing response: %v", err)
+	}
+
+	c.version = version.Name
+	c.versionGroup = version.VersionGroup.Name
+	return nil
+}
+
+// startVersion sets the version given with -version or saved in the
+// config file. One that can't be looked up, for example because PokeAPI
+// is down or missing from the offline dump, only gets a warning, and the
+// Pokedex starts without a version filter.
+func startVersion(ctx context.Context, c *config, name string) {
+	ctx, cancel := context.WithTimeout(ctx, startupVersionTimeout)
+	defer cancel()
+	if err := setVersion(ctx, c, name); err != nil {
+		fmt.Fprintf(c.out, "Warning: version %s ignored: %v\n", name, err)
+	}
+}
+
+func commandVersion(ctx context.Context, c *config, name ...string) error {
+	if len(name) == 0 {
+		if c.version == "" {
+			fmt.Fprintln(c.out, "No game version set, showing all versions")
+			return nil
+		}
+		fmt.Fprintf(c.out, "Game version: %s (%s)\n", c.version, c.versionGroup)
+		return nil
+	}
+
+	if name[0] == "all" {
+		c.version = ""
+		c.versionGroup = ""
+		fmt.Fprintln(c.out, "Showing all versions")
+		return nil
+	}
+
+	if err := setVersion(ctx, c, name[0]); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "Game version set to %s (%s)\n", c.version, c.versionGroup)
+	return nil
+}
+
+func fetchPokemon(ctx context.Context, c *config, name string) (pokemonDetails, error) {
+	url := c.apiBase + "pokemon/" + name
+	pokemon, err := c.pokemon.Load(url, func() ([]byte, error) {
+		return fetch(ctx, c, url)
+	})
+	if errors.Is(err, pokeapi.ErrNotFound) {
+		return pokemon, fmt.Errorf("pokemon '%s' not found", name)
+	}
+	return pokemon, err
+}
+
+func decodePokemon(data []byte) (pokemonDetails, error) {
+	var pokemon pokemonDetails
+	if err := json.Unmarshal(data, &pokemon); err != nil {
+		return pokemon, fmt.Errorf("error parsing response: %v", err)
+	}
+	return pokemon, nil
+}
+
+func commandMoves(ctx context.Context, c *config, name ...string) error {
+	pokemon, err := fetchPokemon(ctx, c, name[0])
+	if err != nil {
+		return err
+	}
+
+	if c.versionGroup == "" {
+		fmt.Fprintf(c.out, "Moves for %s:\n", pokemon.Name)
+		for _, item := range pokemon.Moves {
+			fmt.Fprintf(c.out, " - %s\n", item.Move.Name)
+		}
+		return nil
+	}
+
+	fmt.Fprintf(c.out, "Moves for %s in %s:\n", pokemon.Name, c.versionGroup)
+	count := 0
+	for _, item := range pokemon.Moves {
+		for _, detail := range item.VersionGroupDetails {
+			if detail.VersionGroup.Name != c.versionGroup {
+				continue
+			}
+			if detail.MoveLearnMethod.Name == "level-up" {
+				fmt.Fprintf(c.out, " - %s (level %d)\n", item.Move.Name, detail.LevelLearnedAt)
+			} else {
+				fmt.Fprintf(c.out, " - %s (%s)\n", item.Move.Name, detail.MoveLearnMethod.Name)
+			}
+			count++
+		}
+	}
+	if count == 0 {
+		fmt.Fprintln(c.out, "No moves in this version")
+	}
+	return nil
+}
+
+func commandWhere(ctx context.Context, c *config, name ...string) error {
+	pokemon, err := fetchPokemon(ctx, c, name[0])
+	if err != nil {
+		return err
+	}
+
+	body, err := fetch(ctx, c, pokemon.LocationAreaEncounters)
+	if err != nil {
+		return err
+	}
+
+	var encounters pokemonEncounters
+	if err := json.Unmarshal(body, &encounters); err != nil {
+		return fmt.Errorf("error parsing response: %v", err)
+	}
+
+	fmt.Fprintf(c.out, "%s can be found in:\n", pokemon.Name)
+	count := 0
+	for _, item := range encounters {
+		for _, detail := range item.VersionDetails {
+			if c.version != "" && detail.Version.Name != c.version {
+				continue
+			}
+			if c.version == "" {
+				fmt.Fprintf(c.out, " - %s (%s)\n", item.LocationArea.Name, detail.Version.Name)
+			} else {
+				fmt.Fprintf(c.out, " - %s (%d%%)\n", item.LocationArea.Name, detail.MaxChance)
+			}
+			count++
+		}
+	}
+	if count == 0 {
+		fmt.Fprintln(c.out, "No wild encounters")
+	}
+	return nil
+}